@@ -1,25 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"reflect"
+	"sort"
 	osam "src/osam_simulator"
+	"strings"
+	"sync"
 )
 
 type Block = osam.Block
 
-// ----------- toggle printing -----------
-const printORAM = true // ORAM calls (oram_sim.go)
-const printOSAM = true // OSAM calls (osam.go)
-const printSP = true   // SmartPointer interface calls (smartpointers.go)
-const printPath = true
+// ----------- logging -----------
+// Used by the demo tests that trace their calls; e.g. pass osam.LevelInfo for the pointer API
+// calls only, or tags (osam.TagORAM, osam.TagOSAM, osam.TagSP, osam.TagBSP, osam.TagIG) to
+// restrict the output to some components
+var logger = osam.NewLogger(os.Stdout, osam.LevelDebug)
+
+// ----------- ORAM backend -----------
+const oramMode = osam.TreeORAM // osam.IdealORAM for the fast map-based simulation
 
 // ------ OSAM: Smart Pointer frameworks ------
 func testBSPBaseCase() {
-	or := osam.CreateORAM(50, printORAM)
-	os := osam.CreateOSAM(or, printOSAM)
-	bsp := osam.CreateBSP(os, printSP, printPath)
-
-	osam.Suppress()
+	or := osam.CreateORAM(50, oramMode)
+	os := osam.CreateOSAM(or, nil)
+	bsp := osam.CreateBSP(os)
 
 	fmt.Println("\n[main] Create pointer A to Node with data='MYDATA'")
 	A := bsp.New(Block{Data: "MYDATA", IsNone: false})
@@ -78,11 +86,9 @@ func testBSPBaseCase() {
 }
 
 func testBSP() {
-	or := osam.CreateORAM(50, printORAM)
-	os := osam.CreateOSAM(or, printOSAM)
-	bsp := osam.CreateBSP(os, printSP, printPath)
-
-	osam.Suppress()
+	or := osam.CreateORAM(50, oramMode)
+	os := osam.CreateOSAM(or, nil)
+	bsp := osam.CreateBSP(os)
 
 	fmt.Println("\n[main] Create pointer A to Node with data='MYDATA'")
 	A := bsp.New(Block{Data: "MYDATA", IsNone: false})
@@ -102,7 +108,7 @@ func testBSP() {
 	G := bsp.Copy(&A)
 	H := bsp.Copy(&A)
 
-	// osam.Unsupress()
+	// os.SetLogger(logger)
 
 	fmt.Println("\n[main] GET on pointer A")
 	_ = bsp.Get(&A).Data
@@ -166,9 +172,10 @@ func testBSP() {
 }
 
 func testBasicSP() {
-	or := osam.CreateORAM(12, printORAM)
-	os := osam.CreateOSAM(or, printOSAM)
-	sp := osam.CreateSP(os, printSP, printPath)
+	or := osam.CreateORAM(12, oramMode)
+	os := osam.CreateOSAM(or, nil)
+	os.SetLogger(logger)
+	sp := osam.CreateSP(os)
 
 	fmt.Println("\n[main] Create pointer A to Node with data='DATA'")
 	A := sp.New(Block{Data: "DATA", IsNone: false})
@@ -186,11 +193,9 @@ func testBasicSP() {
 
 func testSP() {
 	// Efficient (balanced) SP program
-	or := osam.CreateORAM(50, printORAM)
-	os := osam.CreateOSAM(or, printOSAM)
-	sp := osam.CreateSP(os, printSP, printPath)
-
-	osam.Suppress()
+	or := osam.CreateORAM(50, oramMode)
+	os := osam.CreateOSAM(or, nil)
+	sp := osam.CreateSP(os)
 
 	fmt.Println("\n[main] Create pointer A to Node with data='MYDATA'")
 	A := sp.New(Block{Data: "MYDATA", IsNone: false})
@@ -210,7 +215,7 @@ func testSP() {
 	fmt.Println("\n[main] Delete pointer C")
 	sp.Delete(&C)
 
-	// osam.Unsupress()
+	// os.SetLogger(logger)
 	fmt.Println("\n[main] GET on pointer A")
 	_ = sp.Get(&A).Data
 	fmt.Println("\n[main] GET on pointer B")
@@ -223,9 +228,443 @@ func testSP() {
 	_ = sp.Get(&E).Data
 }
 
+// ------ ORAM: backends compared on a BSP workload ------
+func workload(sp osam.SmartPointerAPI) {
+	A := sp.New(Block{Data: "MYDATA", IsNone: false})
+	ptrs := []osam.Ptr{}
+	for i := 0; i < 16; i++ {
+		ptrs = append(ptrs, sp.Copy(&A))
+	}
+	for i := range ptrs {
+		_ = sp.Get(&ptrs[i])
+	}
+}
+
+func bspWorkload(os *osam.OSAM) {
+	workload(osam.CreateBSP(os))
+}
+
+// BSP if [balanced], else SP, over [o]
+func newPointers(o *osam.OSAM, balanced bool) osam.SmartPointerAPI {
+	if balanced {
+		return osam.CreateBSP(o)
+	}
+	return osam.CreateSP(o)
+}
+
+// The same workload against both SmartPointer variants
+func testPointerAPI() {
+	for _, balanced := range []bool{false, true} {
+		o := osam.CreateOSAM(osam.CreateORAM(64, osam.IdealORAM), osam.SeededRand(1))
+		workload(newPointers(o, balanced))
+		fmt.Printf("[main] balanced=%v: %+v \n", balanced, o.Metrics().Total)
+	}
+}
+
+func testStash() {
+	or := osam.CreateORAM(64, osam.TreeORAM)
+	or.SetStashBound(20, osam.StashOverflowLog)
+
+	bspWorkload(osam.CreateOSAM(or, nil))
+
+	stats := or.StashStats()
+	fmt.Printf("[main] Stash: max=%v, accesses=%v, overflows=%v \n", stats.Max, stats.Accesses, len(stats.Overflows))
+	fmt.Printf("[main] Stash histogram: %v \n", stats.Histogram)
+}
+
+func testBackends() {
+	backends := map[string]osam.ORAM{
+		"ideal":   osam.CreateORAM(64, osam.IdealORAM),
+		"path":    osam.CreateORAM(64, osam.TreeORAM),
+		"linear":  osam.CreateLinearORAM(64),
+		"circuit": osam.CreateCircuitORAM(64),
+		"ring":    osam.CreateRingORAM(64),
+	}
+
+	for _, name := range []string{"ideal", "path", "circuit", "ring", "linear"} {
+		or := backends[name]
+		bspWorkload(osam.CreateOSAM(or, nil))
+		fmt.Printf("[main] %v: %+v \n", name, or.Stats())
+	}
+}
+
+// OSAM (leaf carried in the address) vs. plain ORAM with a recursive position map
+func testPosMap() {
+	plain := osam.CreateORAM(64, osam.TreeORAM)
+	recursive := osam.CreateRecursiveORAM(64, 1<<14)
+
+	bspWorkload(osam.CreateOSAM(plain, nil))
+	bspWorkload(osam.CreateOSAM(recursive, nil))
+
+	// peak client storage in both: the OSAM's stash vs. the client map plus every stash
+	fmt.Printf("[main] OSAM: %+v, peak client blocks=%v \n", plain.Stats(), plain.StashStats().Max)
+	fmt.Printf("[main] ORAM + %v position map levels: %+v, peak client blocks=%v \n",
+		recursive.Levels(), recursive.Stats(), recursive.PeakClientStorage())
+}
+
+// Same workload on AES-GCM encrypted buckets
+func testEncryption() {
+	or := osam.CreateORAM(64, osam.TreeORAM)
+	if err := or.EnableEncryption([]byte("0123456789abcdef")); err != nil {
+		panic(err)
+	}
+
+	bspWorkload(osam.CreateOSAM(or, nil))
+	fmt.Printf("[main] Encrypted path ORAM: %+v \n", or.Stats())
+}
+
+// Merkle-verified buckets, then a tampered root bucket
+func testIntegrity() {
+	or := osam.CreateORAM(64, osam.TreeORAM)
+	or.EnableIntegrity()
+	os := osam.CreateOSAM(or, nil)
+
+	bspWorkload(os)
+	fmt.Printf("[main] Verified path ORAM: %+v \n", or.Stats())
+
+	defer func() {
+		fmt.Printf("[main] After tampering: %v \n", recover())
+	}()
+	or.CorruptBucket(1)
+	bspWorkload(os)
+}
+
+// Two runs with the same seed must replay exactly
+func testReplay() {
+	series := [2][]int{}
+	for i := range series {
+		or := osam.CreateORAM(8, osam.TreeORAM)
+		or.RecordStashSeries()
+		bspWorkload(osam.CreateOSAM(or, osam.SeededRand(42)))
+		series[i] = or.StashStats().Series
+	}
+	fmt.Printf("[main] Same stash trace across seeded runs: %v \n", fmt.Sprint(series[0]) == fmt.Sprint(series[1]))
+
+	// encryption enabled before the OSAM sets the seed: the ciphertexts must still repeat
+	digests := [2][]byte{}
+	for i := range digests {
+		or := osam.CreateORAM(8, osam.TreeORAM)
+		if err := or.EnableEncryption([]byte("0123456789abcdef")); err != nil {
+			panic(err)
+		}
+		bspWorkload(osam.CreateOSAM(or, osam.SeededRand(42)))
+		digests[i] = or.ServerDigest()
+	}
+	fmt.Printf("[main] Same ciphertexts across seeded runs: %v \n", bytes.Equal(digests[0], digests[1]))
+}
+
+// What the server sees during a small SmartPointer program
+func testTranscript() {
+	or := osam.CreateORAM(4, osam.TreeORAM)
+	o := osam.CreateOSAM(or, osam.SeededRand(1))
+	rec := osam.NewTranscript()
+	o.SetRecorder(rec)
+
+	sp := osam.CreateSP(o)
+	A := sp.New(Block{Data: "DATA", IsNone: false})
+	_ = sp.Get(&A)
+
+	if err := rec.WriteCSV(os.Stdout); err != nil {
+		panic(err)
+	}
+	if err := rec.WriteJSONL(os.Stdout); err != nil {
+		panic(err)
+	}
+}
+
+// Same operation shape, different secret data / pointer structure
+func testObliviousness() {
+	cfg := osam.ObliviousnessConfig{Runs: 200, Alpha: 0.01, Seed: 7,
+		NewORAM: func() osam.ORAM { return osam.CreateORAM(16, osam.TreeORAM) }}
+
+	withData := func(data string) osam.Program {
+		return func(o *osam.OSAM) {
+			bsp := osam.CreateBSP(o)
+			A := bsp.New(Block{Data: data, IsNone: false})
+			B := bsp.Copy(&A)
+			bsp.Put(&B, Block{Data: data + "'", IsNone: false})
+			_ = bsp.Get(&A)
+		}
+	}
+	r := osam.CompareTranscripts(withData("X"), withData("Y"), cfg)
+	fmt.Printf("[main] BSP, different data: leaky=%v %+v \n", r.Leaky, r)
+
+	// unbalanced SmartPointers: how deep [first] ends up depends on which pointer is copied
+	copies := func(copyFirst bool) osam.Program {
+		return func(o *osam.OSAM) {
+			sp := osam.CreateSP(o)
+			p := sp.New(Block{Data: "DATA", IsNone: false})
+			first := sp.Copy(&p)
+			for i := 0; i < 4; i++ {
+				if copyFirst {
+					_ = sp.Copy(&first)
+				} else {
+					_ = sp.Copy(&p)
+				}
+			}
+			_ = sp.Get(&first)
+		}
+	}
+	r = osam.CompareTranscripts(copies(false), copies(true), cfg)
+	fmt.Printf("[main] SP, copies of p vs of first: leaky=%v %v \n", r.Leaky, r.Reasons)
+
+	// blocks read back in the order they were written vs. reversed: only a backend whose
+	// write-backs link to later reads tells these apart
+	order := func(reversed bool) osam.Program {
+		return func(o *osam.OSAM) {
+			var reads []func() error
+			for i := 0; i < 8; i++ {
+				a := o.Alloc("block")
+				if err := o.Write(a, i, ""); err != nil {
+					panic(err)
+				}
+				reads = append(reads, func() error { _, err := o.Read(a); return err })
+			}
+			for i := range reads {
+				if reversed {
+					i = len(reads) - 1 - i
+				}
+				if err := reads[i](); err != nil {
+					panic(err)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"ideal", "linear"} {
+		name := name
+		cfg.NewORAM = func() osam.ORAM {
+			if name == "linear" {
+				return osam.CreateLinearORAM(16)
+			}
+			return osam.CreateORAM(16, osam.IdealORAM)
+		}
+		r = osam.CompareTranscripts(order(false), order(true), cfg)
+		fmt.Printf("[main] %v, read in write order vs reversed: leaky=%v %v, not applicable: %v \n",
+			name, r.Leaky, r.Reasons, r.NotApplicable)
+	}
+}
+
+// Cost per API call as the number of copies grows: BSP stays logarithmic, SP does not
+func testMetrics() {
+	for _, n := range []int{4, 16, 64} {
+		o := osam.CreateOSAM(osam.CreateORAM(256, osam.IdealORAM), osam.SeededRand(1))
+		bsp := osam.CreateBSP(o)
+		A := bsp.New(Block{Data: "DATA", IsNone: false})
+		ptrs := make([]osam.Ptr, n)
+		for i := range ptrs {
+			ptrs[i] = bsp.Copy(&A)
+		}
+		for i := range ptrs {
+			_ = bsp.Get(&ptrs[i])
+		}
+
+		o2 := osam.CreateOSAM(osam.CreateORAM(256, osam.IdealORAM), osam.SeededRand(1))
+		sp := osam.CreateSP(o2)
+		last := sp.New(Block{Data: "DATA", IsNone: false})
+		for i := 0; i < n; i++ {
+			last = sp.Copy(&last)
+		}
+		_ = sp.Get(&last)
+
+		fmt.Printf("[main] n=%v: BSP.Get %+v \n", n, o.Metrics().PerCall("BSP.Get"))
+		fmt.Printf("[main] n=%v: SP.Get  %+v \n", n, o2.Metrics().PerCall("SP.Get"))
+	}
+
+	// encrypted and verified: ciphertext bytes and hashes are attributed per operation too
+	or := osam.CreateORAM(256, osam.TreeORAM)
+	if err := or.EnableEncryption([]byte("0123456789abcdef")); err != nil {
+		panic(err)
+	}
+	or.EnableIntegrity()
+	o := osam.CreateOSAM(or, osam.SeededRand(1))
+	bspWorkload(o)
+	fmt.Printf("[main] sealed BSP.Get %+v, server stores %v bytes \n", o.Metrics().PerCall("BSP.Get"), or.Stats().ServerBytes)
+}
+
+type point struct {
+	X, Y int
+}
+
+// Typed pointers over both SmartPointer implementations: struct, integer and byte-slice payloads
+func testTyped() {
+	for _, balanced := range []bool{false, true} {
+		o := osam.CreateOSAM(osam.CreateORAM(256, oramMode), osam.SeededRand(1))
+		sp := newPointers(o, balanced)
+
+		pts := osam.CreateTypedSP[point](sp)
+		A := pts.New(point{1, 2})
+		B := pts.Copy(&A)
+		pts.Put(&B, point{3, 4})
+		fmt.Printf("[main] balanced=%v point: A=%+v B=%+v \n", balanced, pts.Get(&A), pts.Get(&B))
+
+		ints := osam.CreateTypedSP[int](sp)
+		I := ints.New(41)
+		ints.Put(&I, ints.Get(&I)+1)
+		J := ints.Copy(&I)
+		fmt.Printf("[main] balanced=%v int: I=%v J=%v \n", balanced, ints.Get(&I), ints.Get(&J))
+
+		bytes := osam.CreateTypedSP[[]byte](sp)
+		S := bytes.New([]byte("abc"))
+		T := bytes.Copy(&S)
+		bytes.Put(&T, append(bytes.Get(&T), 'd'))
+		fmt.Printf("[main] balanced=%v []byte: S=%q T=%q \n", balanced, bytes.Get(&S), bytes.Get(&T))
+	}
+}
+
+// Long-running create/copy/read/delete cycles: ORAM occupancy must return to zero after each round
+func testLeaks() {
+	for _, balanced := range []bool{false, true} {
+		o := osam.CreateOSAM(osam.CreateORAM(256, oramMode), osam.SeededRand(1))
+		sp := newPointers(o, balanced)
+		for round := 0; round < 5; round++ {
+			A := sp.New(Block{Data: "DATA", IsNone: false})
+			ptrs := []osam.Ptr{A}
+			for i := 0; i < 6; i++ {
+				ptrs = append(ptrs, sp.Copy(&ptrs[i/2]))
+			}
+			for i := range ptrs {
+				_ = sp.Get(&ptrs[i])
+			}
+			for i := range ptrs {
+				sp.Delete(&ptrs[len(ptrs)-1-i])
+			}
+			fmt.Printf("[main] balanced=%v round %v: live blocks %v (peak %v) \n",
+				balanced, round, o.LiveBlocks(), o.PeakLiveBlocks())
+		}
+	}
+}
+
+// Long simulation: the OSAM's address bookkeeping must stay proportional to the live data
+func testGC() {
+	o := osam.CreateOSAM(osam.CreateORAM(1<<10, osam.IdealORAM), osam.SeededRand(1))
+	bsp := osam.CreateBSP(o)
+	ptrs := []osam.Ptr{bsp.New(Block{Data: 0, IsNone: false})}
+	for i := 0; i < 7; i++ {
+		ptrs = append(ptrs, bsp.Copy(&ptrs[0]))
+	}
+	for i := 1; i <= 20000; i++ {
+		p := &ptrs[i%len(ptrs)]
+		if i%2 == 0 {
+			_ = bsp.Get(p)
+		} else {
+			bsp.Put(p, Block{Data: i, IsNone: false})
+		}
+		if i%5000 == 0 {
+			fmt.Printf("[main] after %v ops: %+v \n", i, o.AddrStats())
+		}
+	}
+}
+
+// Misuse of the OSAM comes back as typed errors, or panics in strict mode
+func testErrors() {
+	or := osam.CreateORAM(64, osam.TreeORAM)
+	or.EnableIntegrity()
+	o := osam.CreateOSAM(or, osam.SeededRand(1))
+
+	a := o.Alloc("a")
+	fmt.Printf("[main] first write: %v \n", o.Write(a, "DATA", ""))
+	fmt.Printf("[main] second write is ErrDoubleWrite: %v \n", errors.Is(o.Write(a, "DATA", ""), osam.ErrDoubleWrite))
+	v, err := o.Read(a)
+	fmt.Printf("[main] first read: %v %v \n", v.Data, err)
+	_, err = o.Read(a)
+	fmt.Printf("[main] second read is ErrDoubleRead: %v \n", errors.Is(err, osam.ErrDoubleRead))
+	_, err = osam.CreateOSAM(osam.CreateORAM(64, osam.TreeORAM), nil).Read(a)
+	fmt.Printf("[main] foreign address is ErrNotAllocated: %v \n", errors.Is(err, osam.ErrNotAllocated))
+	// ... unless the other OSAM has already handed out its counter: then it looks like one of its dead addresses
+	o2 := osam.CreateOSAM(osam.CreateORAM(64, osam.TreeORAM), osam.SeededRand(2))
+	for i := 0; i < 5; i++ {
+		o2.Alloc("o2")
+	}
+	_, err = o2.Read(a)
+	fmt.Printf("[main] foreign address below the counter is ErrDoubleRead: %v \n", errors.Is(err, osam.ErrDoubleRead))
+
+	// more blocks than the tree holds: the stash must overflow its bound
+	small := osam.CreateORAM(8, osam.TreeORAM)
+	small.SetStashBound(4, osam.StashOverflowFail)
+	o3 := osam.CreateOSAM(small, osam.SeededRand(1))
+	c := o3.Alloc("c")
+	for i := 0; ; i++ {
+		if err = o3.Write(c, i, ""); errors.Is(err, osam.ErrStashOverflow) {
+			break
+		}
+		c = o3.Alloc("c")
+	}
+	fmt.Printf("[main] full stash is ErrStashOverflow: %v (%v) \n", errors.Is(err, osam.ErrStashOverflow), err)
+	// ... but the value was stored
+	small.SetStashBound(4, osam.StashOverflowLog)
+	fmt.Printf("[main] rewrite after the overflow is ErrDoubleWrite: %v \n", errors.Is(o3.Write(c, "again", ""), osam.ErrDoubleWrite))
+	v, err = o3.Read(c)
+	fmt.Printf("[main] read after the overflow: %v %v \n", v.Data, err)
+
+	or.CorruptBucket(1)
+	var ierr *osam.IntegrityError
+	_, err = o.Read(o.Alloc("b"))
+	fmt.Printf("[main] tampered read is an IntegrityError: %v (%v) \n", errors.As(err, &ierr), err)
+
+	defer func() {
+		fmt.Printf("[main] strict mode panicked with: %v \n", recover())
+	}()
+	o.SetStrict(true)
+	_, _ = o.Read(a)
+}
+
+// Each simulation logs into its own buffer, even when they run at the same time
+func testLogger() {
+	bufs := make([]bytes.Buffer, 2)
+	var wg sync.WaitGroup
+	for i := range bufs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			o := osam.CreateOSAM(osam.CreateORAM(64, osam.TreeORAM), osam.SeededRand(int64(i)))
+			o.SetLogger(osam.NewLogger(&bufs[i], osam.LevelInfo, osam.TagBSP))
+			bspWorkload(o)
+		}(i)
+	}
+	wg.Wait()
+	for i := range bufs {
+		lines := strings.Split(strings.TrimSpace(bufs[i].String()), "\n")
+		fmt.Printf("[main] simulation %v: %v log lines, first: %q \n", i, len(lines), lines[0])
+	}
+}
+
+// Goroutines sharing one OSAM and one object: every pointer operation is atomic, so each Get
+// returns a value some goroutine wrote, and deleting every copy leaves nothing behind
+// (run with -race)
+func testConcurrentSP() {
+	for _, balanced := range []bool{false, true} {
+		o := osam.CreateOSAM(osam.CreateORAM(256, oramMode), osam.SeededRand(1))
+		o.EnableConcurrency()
+		sp := newPointers(o, balanced)
+		shared := sp.New(Block{Data: 0, IsNone: false})
+
+		const workers, rounds = 8, 20
+		var wg sync.WaitGroup
+		bad := make([]int, workers)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				p := sp.Copy(&shared)
+				for r := 0; r < rounds; r++ {
+					sp.Put(&p, Block{Data: w*rounds + r, IsNone: false})
+					if v, ok := sp.Get(&p).Data.(int); !ok || v < 0 || v >= workers*rounds {
+						bad[w]++
+					}
+				}
+				sp.Delete(&p)
+			}(w)
+		}
+		wg.Wait()
+		final := sp.Get(&shared).Data
+		sp.Delete(&shared)
+		fmt.Printf("[main] balanced=%v: final value %v, bad reads %v, live blocks %v \n",
+			balanced, final, bad, o.LiveBlocks())
+	}
+}
+
 // ----------------------------------------------
 // ----- OSAM: Emulated Graph construction ------
-const printGr = true
 
 func testGraph() {
 	// NOTE: needs to include self-vertices
@@ -236,18 +675,311 @@ func testGraph() {
 	vs := []int{1, 2, 3, 4, 6, 2, 3, 3, 4, 3, 5, 5, 3, 6, 4}
 	ws := []int{osam.NONE, 13, 13, 13, 13, osam.NONE, 13, osam.NONE, osam.NONE, 13, 13, osam.NONE, 13, osam.NONE, 13}
 
-	inp := osam.CreateInputGraph(printGr, us, vs, ws)
-	og := inp.CreateOSAMGraph()
-
+	o := osam.CreateOSAM(osam.CreateORAM(256, osam.TreeORAM), osam.SeededRand(1))
+	o.SetLogger(osam.NewLogger(os.Stdout, osam.LevelDebug, osam.TagIG))
+	rec := osam.NewTranscript()
+	o.SetRecorder(rec)
+	inp := osam.CreateInputGraph(us, vs, ws)
+	og := inp.CreateOSAMGraph(o)
+	fmt.Printf("[main] Construction: %+v, %v server events, at most %v vertices held by the client \n",
+		o.Metrics().Ops["IG.construct"], len(rec.Events), og.PeakPending())
+
+	if err := og.Load(); err != nil {
+		panic(err)
+	}
 	for i := 0; i < len(og.Vtcs); i++ {
 		vtx := og.Vtcs[i]
-		fmt.Printf("Vtx @ index %v: %v @ address %v \n", i, og.FakeRAM[vtx], vtx)
-		for vtx != osam.NONE {
-			vtx = og.FakeRAM[vtx].UP
-			fmt.Printf("%v @ address %v \n", og.FakeRAM[vtx], vtx)
+		fmt.Printf("Vtx @ index %v: %v @ address %v \n", i, og.Vertices[vtx], vtx)
+		for vtx != osam.NIL {
+			vtx = og.Vertices[vtx].UP
+			fmt.Printf("%v @ address %v \n", og.Vertices[vtx], vtx)
+		}
+	}
+
+	// every Out leaf leads through its Inc leaf and the in-tree up to the head of the edge
+	for _, vtx := range og.Vtcs {
+		if og.Vertices[vtx].Type != osam.Out {
+			continue
+		}
+		head := og.Vertices[vtx].Other
+		for og.Vertices[head].Type != osam.Real {
+			head = og.Vertices[head].UP
+		}
+		fmt.Printf("Edge %v -> %v \n", og.Vertices[vtx].Id, og.Vertices[head].Id)
+	}
+	fmt.Printf("[main] Degree check: %v \n", og.VerifyDegrees())
+
+	// the same graph in an encrypted, integrity-checked ORAM: vertices survive the round trip
+	or := osam.CreateORAM(256, osam.TreeORAM)
+	if err := or.EnableEncryption([]byte("0123456789abcdef")); err != nil {
+		panic(err)
+	}
+	or.EnableIntegrity()
+	sealed := osam.CreateInputGraph(us, vs, ws).CreateOSAMGraph(osam.CreateOSAM(or, osam.SeededRand(1)))
+	fmt.Printf("[main] Encrypted degree check: %v, %+v \n", sealed.VerifyDegrees(), or.Stats())
+
+	// random graphs, including vertices with no in- or out-edges
+	rng := osam.SeededRand(3)
+	for trial := 0; trial < 20; trial++ {
+		n := 1 + rng.Intn(12)
+		us, vs, ws = nil, nil, nil
+		for u := 0; u < n; u++ {
+			us, vs, ws = append(us, u), append(vs, u), append(ws, osam.NONE)
+			for v := 0; v < n; v++ {
+				if v != u && rng.Intn(3) == 0 {
+					us, vs, ws = append(us, u), append(vs, v), append(ws, 1+rng.Intn(20))
+				}
+			}
+		}
+		o := osam.CreateOSAM(osam.CreateORAM(256, osam.TreeORAM), osam.SeededRand(int64(trial)))
+		og := osam.CreateInputGraph(us, vs, ws).CreateOSAMGraph(o)
+		if err := og.VerifyDegrees(); err != nil {
+			fmt.Printf("[main] trial %v: %v \n", trial, err)
+			return
+		}
+		if o.LiveBlocks() != 0 {
+			fmt.Printf("[main] trial %v: %v blocks left after Load \n", trial, o.LiveBlocks())
+			return
+		}
+	}
+	fmt.Println("[main] Degree check passed on 20 random graphs")
+}
+
+// Edge list with a self entry per vertex, as InputGraph expects, for the edges u -> adj(u)
+func graphInput(n int, adj func(u int) []int) ([]int, []int, []int) {
+	var us, vs, ws []int
+	for u := 0; u < n; u++ {
+		us, vs, ws = append(us, u), append(vs, u), append(ws, osam.NONE)
+		for _, v := range adj(u) {
+			us, vs, ws = append(us, u), append(vs, v), append(ws, 1+u+v)
+		}
+	}
+	return us, vs, ws
+}
+
+// Graphs with the same number of vertices and edges but different shapes: in assertion mode every
+// streaming step must cost the same on each backend, and the transcripts must look alike
+func testGraphPadding() {
+	const n = 8
+	star := func(u int) []int {
+		if u > 0 {
+			return nil
+		}
+		var out []int
+		for v := 1; v < n; v++ {
+			out = append(out, v)
+		}
+		return out
+	}
+	path := func(u int) []int {
+		if u+1 < n {
+			return []int{u + 1}
+		}
+		return nil
+	}
+	build := func(adj func(u int) []int, o *osam.OSAM) {
+		us, vs, ws := graphInput(n, adj)
+		inp := osam.CreateInputGraph(us, vs, ws)
+		inp.SetCheckSteps(true)
+		inp.CreateOSAMGraph(o)
+	}
+
+	backends := map[string]func() osam.ORAM{
+		"path":      func() osam.ORAM { return osam.CreateORAM(64, osam.TreeORAM) },
+		"circuit":   func() osam.ORAM { return osam.CreateCircuitORAM(64) },
+		"ring":      func() osam.ORAM { return osam.CreateRingORAM(64) },
+		"linear":    func() osam.ORAM { return osam.CreateLinearORAM(64) },
+		"recursive": func() osam.ORAM { return osam.CreateRecursiveORAM(64, 1<<10) },
+	}
+	for _, name := range []string{"path", "circuit", "ring", "linear", "recursive"} {
+		for _, adj := range []func(u int) []int{star, path} {
+			build(adj, osam.CreateOSAM(backends[name](), osam.SeededRand(1)))
 		}
+		fmt.Printf("[main] %v: every step of both constructions costs the same \n", name)
 	}
 
+	cfg := osam.ObliviousnessConfig{Runs: 100, Alpha: 0.01, Seed: 3, NewORAM: backends["path"]}
+	r := osam.CompareTranscripts(func(o *osam.OSAM) { build(star, o) }, func(o *osam.OSAM) { build(path, o) }, cfg)
+	fmt.Printf("[main] Star vs path construction: leaky=%v %v \n", r.Leaky, r.Reasons)
+}
+
+// Textbook BFS over the edge list, for checking the oblivious one
+func plainBFS(us, vs, ws []int, source int) map[int]int {
+	dist := map[int]int{}
+	adj := map[int][]int{}
+	for i := range us {
+		if ws[i] == osam.NONE {
+			dist[us[i]] = osam.NONE
+		} else {
+			adj[us[i]] = append(adj[us[i]], vs[i])
+		}
+	}
+	if _, ok := dist[source]; !ok {
+		return dist
+	}
+	dist[source] = 0
+	queue := []int{source}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, v := range adj[u] {
+			if dist[v] == osam.NONE {
+				dist[v] = dist[u] + 1
+				queue = append(queue, v)
+			}
+		}
+	}
+	return dist
+}
+
+// Oblivious BFS against plain BFS, then two graphs with the same V and E: the messages live in
+// the OSAM, so the transcript is the BFS itself and must not tell the graphs apart.
+// NOTE: kept small, every round sorts all messages twice (see graph_bfs.go)
+func testBFS() {
+	rng := osam.SeededRand(9)
+	for trial := 0; trial < 10; trial++ {
+		n := 1 + rng.Intn(4)
+		p := 1 + rng.Intn(n)
+		us, vs, ws := graphInput(n, func(u int) []int {
+			var out []int
+			for v := 0; v < n; v++ {
+				if v != u && rng.Intn(p) == 0 {
+					out = append(out, v)
+				}
+			}
+			return out
+		})
+		source := rng.Intn(n)
+		want := plainBFS(us, vs, ws, source)
+
+		o := osam.CreateOSAM(osam.CreateORAM(256, osam.IdealORAM), osam.SeededRand(int64(trial)))
+		res, err := osam.CreateInputGraph(us, vs, ws).CreateOSAMGraph(o).BFS(source)
+		if err != nil {
+			panic(err)
+		}
+		if !reflect.DeepEqual(res.Dist, want) {
+			fmt.Printf("[main] trial %v: BFS from %v gave %v, want %v \n", trial, source, res.Dist, want)
+			return
+		}
+		if o.LiveBlocks() != 0 {
+			fmt.Printf("[main] trial %v: %v blocks left after BFS \n", trial, o.LiveBlocks())
+			return
+		}
+	}
+	fmt.Println("[main] Oblivious BFS matches plain BFS on 10 random graphs")
+
+	// larger graphs: the 3 MaxVertices messages are padded up to the next power of 2
+	for trial := 0; trial < 5; trial++ {
+		n := 5 + rng.Intn(3)
+		us, vs, ws := graphInput(n, func(u int) []int {
+			var out []int
+			for v := 0; v < n; v++ {
+				if v != u && rng.Intn(3) == 0 {
+					out = append(out, v)
+				}
+			}
+			return out
+		})
+		source := rng.Intn(n)
+		want := plainBFS(us, vs, ws, source)
+
+		o := osam.CreateOSAM(osam.CreateORAM(256, osam.IdealORAM), osam.SeededRand(int64(trial)))
+		og := osam.CreateInputGraph(us, vs, ws).CreateOSAMGraph(o)
+		msgs := 3 * og.MaxVertices()
+		res, err := og.BFS(source)
+		if err != nil {
+			panic(err)
+		}
+		if !reflect.DeepEqual(res.Dist, want) || o.LiveBlocks() != 0 {
+			fmt.Printf("[main] V=%v, E=%v: BFS from %v gave %v, want %v (%v blocks left) \n",
+				n, len(us)-n, source, res.Dist, want, o.LiveBlocks())
+			return
+		}
+		fmt.Printf("[main] V=%v, E=%v, %v messages: BFS from %v matches plain BFS, %v rounds \n",
+			n, len(us)-n, msgs, source, res.Rounds)
+	}
+
+	// star and path: same V and E, different shapes and distances
+	const n = 4
+	star := func(u int) []int {
+		if u > 0 {
+			return nil
+		}
+		return []int{1, 2, 3}
+	}
+	path := func(u int) []int {
+		if u+1 < n {
+			return []int{u + 1}
+		}
+		return nil
+	}
+	run := func(adj func(u int) []int, o *osam.OSAM) osam.BFSResult {
+		us, vs, ws := graphInput(n, adj)
+		og := osam.CreateInputGraph(us, vs, ws).CreateOSAMGraph(o)
+		o.ResetMetrics()
+		res, err := og.BFS(0)
+		if err != nil {
+			panic(err)
+		}
+		return res
+	}
+	for _, adj := range []func(u int) []int{star, path} {
+		o := osam.CreateOSAM(osam.CreateORAM(64, osam.TreeORAM), osam.SeededRand(1))
+		rec := osam.NewTranscript()
+		o.SetRecorder(rec)
+		res := run(adj, o)
+		fmt.Printf("[main] %v: %v rounds, %v compare-exchanges, %v OSAM accesses, %v reads and %v writes on the server \n",
+			res.Dist, res.Rounds, res.CompareExchanges, o.Metrics().Ops["IG.bfs"].Accesses,
+			len(rec.Leaves(osam.OpRead)), len(rec.Leaves(osam.OpWrite)))
+	}
+	cfg := osam.ObliviousnessConfig{Runs: 4, Alpha: 0.01, Seed: 5,
+		NewORAM: func() osam.ORAM { return osam.CreateORAM(64, osam.IdealORAM) }}
+	r := osam.CompareTranscripts(func(o *osam.OSAM) { run(star, o) }, func(o *osam.OSAM) { run(path, o) }, cfg)
+	fmt.Printf("[main] Star vs path construction + BFS: leaky=%v %v \n", r.Leaky, r.Reasons)
+}
+
+// Records the positions of every compare-exchange of a sort
+type schedule struct {
+	sort.IntSlice
+	pairs [][2]int
+}
+
+func (s *schedule) Less(i, j int) bool {
+	s.pairs = append(s.pairs, [2]int{i, j})
+	return s.IntSlice.Less(i, j)
+}
+
+// Bitonic sort sorts every length, and its compare-exchange schedule depends only on the length
+func testOSort() {
+	rng := osam.SeededRand(5)
+	for n := 0; n <= 70; n++ {
+		a, b := make([]int, n), make([]int, n)
+		for i := range a {
+			a[i], b[i] = rng.Intn(10), i
+		}
+		want := append([]int(nil), a...)
+		sort.Ints(want)
+		sa, sb := &schedule{IntSlice: a}, &schedule{IntSlice: b}
+		ops := osam.BitonicSort(sa)
+		osam.BitonicSort(sb)
+		if fmt.Sprint(a) != fmt.Sprint(want) {
+			fmt.Printf("[main] n=%v: not sorted: %v \n", n, a)
+			return
+		}
+		if !reflect.DeepEqual(sa.pairs, sb.pairs) || ops != osam.BitonicOps(n) {
+			fmt.Printf("[main] n=%v: schedule depends on the data \n", n)
+			return
+		}
+	}
+	fmt.Println("[main] Bitonic sort passed for n = 0..70")
+
+	us := []int{1, 1, 1, 1, 1, 2, 2, 3, 4, 4, 4, 5, 5, 6, 6}
+	vs := []int{1, 2, 3, 4, 6, 2, 3, 3, 4, 3, 5, 5, 3, 6, 4}
+	ws := []int{osam.NONE, 13, 13, 13, 13, osam.NONE, 13, osam.NONE, osam.NONE, 13, 13, osam.NONE, 13, osam.NONE, 13}
+	inp := osam.CreateInputGraph(us, vs, ws)
+	og := inp.CreateOSAMGraph(osam.CreateOSAM(osam.CreateORAM(256, osam.IdealORAM), osam.SeededRand(1)))
+	fmt.Printf("[main] Graph construction: %v compare-exchanges for %v entries, degree check: %v \n",
+		inp.CompareExchanges(), len(us), og.VerifyDegrees())
 }
 
 // ----------------------------------------------
@@ -259,6 +991,25 @@ func main() {
 	// testBSP()
 	// testSP()
 	// testBasicSP()
+	// testStash()
+	// testBackends()
+	// testPosMap()
+	// testEncryption()
+	// testIntegrity()
+	// testReplay()
+	// testTranscript()
+	// testObliviousness()
+	// testMetrics()
+	// testPointerAPI()
+	// testTyped()
+	// testLeaks()
+	// testGC()
+	// testErrors()
+	// testLogger()
+	// testConcurrentSP()
+	// testOSort()
+	// testGraphPadding()
+	// testBFS()
 
 	// OLD: OSAM-level program
 	// a1 := os.Alloc()
@@ -12,23 +12,17 @@ import (
 // (3) changes to [chase] (not shown/mentioned in paper)
 
 type BSP struct {
-	osam      *OSAM
-	print     bool
-	nodeId    int
-	printPath bool
+	osam   *OSAM
+	nodeId int
 }
 
-func (bsp *BSP) log(str string, newline bool) {
-	if newline && !suppressPrint {
-		fmt.Println()
-	}
-	if bsp.print && !suppressPrint {
-		fmt.Println("[BSP] " + str)
-	}
+// API calls are logged at LevelInfo, the nodes they visit at LevelDebug
+func (bsp *BSP) logf(level LogLevel, format string, args ...interface{}) {
+	bsp.osam.logger.logf(TagBSP, level, format, args...)
 }
 
-func CreateBSP(osam *OSAM, print bool, printPath bool) *BSP {
-	return &BSP{osam, print, 0, printPath}
+func CreateBSP(osam *OSAM) *BSP {
+	return &BSP{osam, 0}
 }
 
 func (bsp *BSP) newNode() *BNode {
@@ -41,6 +35,7 @@ func (bsp *BSP) newNode() *BNode {
 
 // copy of SP.chase
 func (bsp *BSP) chase(head addr) *BNode {
+	defer bsp.osam.track("BSP.chase")()
 	target := NIL
 	latest := NIL
 	tail := NIL
@@ -49,7 +44,7 @@ func (bsp *BSP) chase(head addr) *BNode {
 		tail = head
 		target, head = bsp.osam.dequeue(head)
 	}
-	nd := bsp.osam.Read(latest).Data.(*BNode)
+	nd := blockAs[*BNode](bsp.osam.mustRead(latest))
 	if nd.tailL == tail {
 		nd.tailL = NIL
 	} else if nd.tailP == tail {
@@ -60,8 +55,15 @@ func (bsp *BSP) chase(head addr) *BNode {
 	return nd
 }
 
+// Reads (and so frees) the queue cells from [head] on, without reading the node they lead to
+func (bsp *BSP) drain(head addr) {
+	for head != NIL {
+		_, head = bsp.osam.dequeue(head)
+	}
+}
+
 func (bsp *BSP) saveNode(nd *BNode) {
-	a := bsp.osam.Alloc(fmt.Sprintf("saveNode %v", nd.id))
+	a := bsp.osam.alloc(fmt.Sprintf("saveNode %v", nd.id))
 	if nd.tailL != NIL {
 		nd.tailL = bsp.osam.enqueue(nd.tailL, a)
 	}
@@ -89,21 +91,33 @@ func (bsp *BSP) addTail(nd *BNode) addr {
 }
 
 // Note: equivalent code to sp.retrieve in smartpointers.go
-func (bsp *BSP) ascend(p *Ptr, printPath bool) *BNode {
+func (bsp *BSP) ascend(p *Ptr) *BNode {
+	defer bsp.osam.track("BSP.ascend")()
 	nd := bsp.chase(p.head)
 	p.head = bsp.addTail(nd)
+	return bsp.climb(nd)
+}
+
+// Second half of [ascend]: walks from [nd] up to the root, saving every node on the way
+func (bsp *BSP) climb(nd *BNode) *BNode {
 	for !nd.isRoot {
-		if printPath {
-			fmt.Printf("Fetched BSP-node: %v \n", nd.id)
-		}
+		bsp.logf(LevelDebug, "Fetched BSP-node: %v", nd.id)
 		parent := bsp.chase(nd.headP)
+		// every save of [nd] appends to the parent's queue to it, and all those copies have been
+		// read by now: drain the queue and start a new one, or it grows with every Get/Put
+		nd.tailP = NIL
+		if parent.tailL == NIL {
+			bsp.drain(parent.headL)
+			parent.headL = bsp.addTail(nd)
+		} else {
+			bsp.drain(parent.headR)
+			parent.headR = bsp.addTail(nd)
+		}
 		nd.headP = bsp.addTail(parent)
 		bsp.saveNode(nd)
 		nd = parent
 	}
-	if printPath {
-		fmt.Printf("Fetched BSP-node: %v \n", nd.id)
-	}
+	bsp.logf(LevelDebug, "Fetched BSP-node: %v", nd.id)
 	assert(nd.isRoot, "Node returned from [ascend] is not root node")
 	return nd
 }
@@ -117,16 +131,18 @@ func getBits(n, len int) []int {
 	return bits
 }
 
-func (bsp *BSP) descend(root *BNode) *BNode {
+// Walks (creating nodes as needed) to the node that holds the pointer slot of copy number [count]
+func (bsp *BSP) descend(root *BNode, count int) *BNode {
+	defer bsp.osam.track("BSP.descend")()
 	assert(root.isRoot, "Node passed to [descend] is not root node")
-	if root.count <= 1 { // short-circuit: should not create / find new node; stay at root
+	if count <= 1 { // short-circuit: should not create / find new node; stay at root
 		return root
 	}
-	pow := int(math.Floor(math.Log2(float64(root.count))))
+	pow := int(math.Floor(math.Log2(float64(count))))
 
 	// TBD: check what the right formula is?
-	rmost := root.count - (1 << pow) // MY SOLUTION
-	// rmost := int(math.Floor(float64(root.count -(1<<pow) - 1)/(2.0)))
+	rmost := count - (1 << pow) // MY SOLUTION
+	// rmost := int(math.Floor(float64(count -(1<<pow) - 1)/(2.0)))
 	nd := root
 	rmostbits := getBits(rmost, pow)
 	for _, b := range rmostbits {
@@ -136,9 +152,7 @@ func (bsp *BSP) descend(root *BNode) *BNode {
 				nextNd = bsp.chase(nd.headL)
 			} else {
 				nextNd = bsp.newNode()
-				if bsp.printPath {
-					fmt.Printf("Created node %v as L child of node %v \n", nextNd.id, nd.id)
-				}
+				bsp.logf(LevelDebug, "Created node %v as L child of node %v", nextNd.id, nd.id)
 				nextNd.tailL = nd.tailL
 				nd.tailL = NIL
 				nextNd.headP = bsp.addTail(nd)
@@ -149,9 +163,7 @@ func (bsp *BSP) descend(root *BNode) *BNode {
 				nextNd = bsp.chase(nd.headR)
 			} else {
 				nextNd = bsp.newNode()
-				if bsp.printPath {
-					fmt.Printf("Created node %v as R child of node %v \n", nextNd.id, nd.id)
-				}
+				bsp.logf(LevelDebug, "Created node %v as R child of node %v", nextNd.id, nd.id)
 				nextNd.tailR = nd.tailR
 				nd.tailR = NIL
 				nextNd.headP = bsp.addTail(nd)
@@ -167,16 +179,18 @@ func (bsp *BSP) descend(root *BNode) *BNode {
 // ------------ BalancedSmartPointer: MAIN API ------------
 //  Get(p: Ptr) -> Block
 //  Put(p: Ptr, c: Block)
-//  IsNull(p: Ptr)
+//  IsNull(p: *Ptr)
 //  Copy(p1: Ptr) -> Ptr
 //  New(c: Block) -> Ptr
 //  Delete(p: Ptr)
 
 func (bsp *BSP) Copy(p1 *Ptr) Ptr {
-	bsp.log(fmt.Sprintf("COPY: copy pointer %v", p1.head), true)
-	root := bsp.ascend(p1, false)
+	defer bsp.osam.lock()()
+	defer bsp.osam.track("BSP.Copy")()
+	bsp.logf(LevelInfo, "COPY: copy pointer %v", p1.head)
+	root := bsp.ascend(p1)
 	root.count++
-	nd := bsp.descend(root)
+	nd := bsp.descend(root, root.count)
 	p0 := Ptr{head: bsp.addTail(nd)}
 	bsp.saveNode(nd)
 	return p0
@@ -184,8 +198,10 @@ func (bsp *BSP) Copy(p1 *Ptr) Ptr {
 
 // Same as SP.Get (with different saveNode implementation)
 func (bsp *BSP) Get(p *Ptr) Block {
-	bsp.log(fmt.Sprintf("GET: %v", p.head), true)
-	nd := bsp.ascend(p, bsp.printPath)
+	defer bsp.osam.lock()()
+	defer bsp.osam.track("BSP.Get")()
+	bsp.logf(LevelInfo, "GET: %v", p.head)
+	nd := bsp.ascend(p)
 	out := nd.content
 	bsp.saveNode(nd)
 	return out
@@ -193,18 +209,23 @@ func (bsp *BSP) Get(p *Ptr) Block {
 
 // Same as SP.Put (with different saveNode implementation)
 func (bsp *BSP) Put(p *Ptr, c Block) {
-	bsp.log(fmt.Sprintf("PUT: content '%v' @ %v", c.Data, p.head), true)
-	nd := bsp.ascend(p, bsp.printPath)
+	defer bsp.osam.lock()()
+	defer bsp.osam.track("BSP.Put")()
+	bsp.logf(LevelInfo, "PUT: content '%v' @ %v", c.Data, p.head)
+	nd := bsp.ascend(p)
 	nd.content = c
 	bsp.saveNode(nd)
 }
 
 func (bsp *BSP) IsNull(p *Ptr) bool {
+	defer bsp.osam.lock()()
 	return p.head == NIL
 }
 
 func (bsp *BSP) New(c Block) Ptr {
-	bsp.log(fmt.Sprintf("NEW: create pointer to content %v", c.Data), true)
+	defer bsp.osam.lock()()
+	defer bsp.osam.track("BSP.New")()
+	bsp.logf(LevelInfo, "NEW: create pointer to content %v", c.Data)
 	nd := bsp.newNode()
 	// set root node properties
 	nd.content = c
@@ -215,60 +236,67 @@ func (bsp *BSP) New(c Block) Ptr {
 	return p
 }
 
+// Removes the leaf created by the latest Copy and moves its two pointers into the slots freed by
+// [p] and by the leaf itself, so the tree stays balanced. When the last pointer goes, the root
+// (and with it the content) is not saved back, so nothing of the object is left in the ORAM.
 func (bsp *BSP) Delete(p *Ptr) {
-	bsp.log(fmt.Sprintf("DELETE: %v", p.head), true)
-	root := bsp.ascend(p, false)
-	nd := bsp.descend(root)
-	root.count -= 1  // NOTE: NEW -- doing this AFTER the descend
-	bsp.saveNode(nd) // NEW
+	defer bsp.osam.lock()()
+	defer bsp.osam.track("BSP.Delete")()
+	bsp.logf(LevelInfo, "DELETE: %v", p.head)
+	if p.head == NIL {
+		return
+	}
+	// same as [ascend], remembering which slot of which node p occupies
+	pNode := bsp.chase(p.head)
+	p.head = bsp.addTail(pNode)
+	pId, pLeft := pNode.id, pNode.tailL == p.head
+	root := bsp.climb(pNode)
+	count := root.count
+	root.count--
+	nd := bsp.descend(root, count)
 
 	if nd.isRoot {
-		bsp.chase(p.head) // to destroy the AQ between the root and p
+		// no other nodes, so p points straight at the (unsaved) root
+		if pLeft {
+			nd.tailL = NIL
+		} else {
+			nd.tailR = NIL
+		}
 		if nd.tailL == NIL && nd.tailR == NIL {
-			fmt.Printf("All pointers to Node %v deleted; should delete its content \n", nd.id)
+			bsp.logf(LevelInfo, "All pointers to Node %v deleted; freed its content", nd.id)
 		} else {
 			bsp.saveNode(nd)
 		}
+		p.head = NIL
 		return
 	}
 
-	tailLatest := nd.tailR
-	ndPrime := bsp.chase(p.head) // important: ndPrime *could be the same* as nd
-	if ndPrime.tailR == NIL {
-		ndPrime.tailR = tailLatest
+	// [nd] is a leaf holding two pointers; one goes back to the parent's slot, the other takes
+	// over the slot of p (unless p is one of them)
+	moved, kept := nd.tailL, nd.tailR
+	if nd.id == pId {
+		if pLeft {
+			moved = kept
+		}
+		bsp.drain(p.head)
 	} else {
-		ndPrime.tailL = tailLatest
+		ndPrime := bsp.chase(p.head)
+		if ndPrime.tailR == NIL {
+			ndPrime.tailR = kept
+		} else {
+			ndPrime.tailL = kept
+		}
+		bsp.saveNode(ndPrime)
 	}
-	bsp.saveNode(ndPrime)
 	parent := bsp.chase(nd.headP)
+	// the parent's queue to [nd] was created by [descend] and is still empty
 	if parent.tailL == NIL {
-		parent.tailL = nd.tailL
-		parent.headL = NIL // NEW
+		parent.tailL = moved
+		parent.headL = NIL
 	} else {
-		parent.tailR = nd.tailL
-		parent.headR = NIL // NEW
+		parent.tailR = moved
+		parent.headR = NIL
 	}
 	bsp.saveNode(parent)
-
-	// OLD CODE: based on paper pseudodcode
-	// root := bsp.ascend(p, false)
-	// nd := bsp.descend(root)
-	// root.count -= 1
-	// fmt.Println(nd.id)
-	// // NOTE: NEW -- doing this AFTER the descend?
-	// tailLatest := nd.tailR
-	// parent := bsp.chase(nd.headP)
-	// if parent.tailL == NIL {
-	// 	parent.tailL = nd.tailL
-	// } else {
-	// 	parent.tailR = nd.tailL
-	// }
-	// bsp.saveNode(parent)
-	// ndPrime := bsp.chase(p.head)
-	// if ndPrime.tailR == NIL {
-	// 	ndPrime.tailR = tailLatest
-	// } else {
-	// 	ndPrime.tailL = tailLatest
-	// }
-	// bsp.saveNode(ndPrime)
+	p.head = NIL
 }
@@ -0,0 +1,247 @@
+package osam_simulator
+
+import "math/rand"
+
+// ------------- Bucket tree (shared by the tree-based ORAMs) ------------- //
+// Buckets are stored in heap order: node 1 is the root, node n has children 2n and 2n+1,
+// and leaf i is node (1 << depth) + i. Leaves past [nl] exist in the tree but are never used.
+
+// A real block held in a bucket or in the client stash, tagged with its OSAM address
+type slot struct {
+	a addr
+	b Block
+}
+
+type bucketTree struct {
+	nl      int
+	depth   int // number of edges on a root-to-leaf path
+	z       int // bucket capacity
+	buckets [][]slot
+	rng     *rand.Rand
+	rec     *Transcript // server-visible accesses, if recording
+	id      int         // tree number reported in the transcript
+
+	// With encryption enabled, [sealed] is the server's copy of every bucket and [buckets]
+	// only holds the plaintext of paths the client currently has open
+	sealer      *sealer
+	sealed      [][]byte
+	sealPending bool // encryption enabled, buckets not sealed yet (see [enableEncryption])
+	serverBytes int  // total length of [sealed], kept up to date by [seal]
+
+	// Optional Merkle tree over the server's buckets (see merkle.go)
+	merkle *merkleTree
+}
+
+func createBucketTree(nleaves int, z int) *bucketTree {
+	t := &bucketTree{nl: nleaves, z: z, rng: SecureRand()}
+	t.depth = lg(nextPowTwo(nleaves))
+	t.buckets = make([][]slot, 1<<(t.depth+1))
+	return t
+}
+
+// Heap index of the bucket at level [lvl] (root = 0) on the path to [leaf]
+func (t *bucketTree) node(leaf, lvl int) int {
+	return ((1 << t.depth) + leaf) >> (t.depth - lvl)
+}
+
+// Heap indices of the buckets on the path to [leaf], ordered root first
+func (t *bucketTree) path(leaf int) []int {
+	return heapPath(t.depth, leaf)
+}
+
+// Heap indices of the path to [leaf] in a tree of depth [depth], root first
+func heapPath(depth, leaf int) []int {
+	p := make([]int, depth+1)
+	for lvl := 0; lvl <= depth; lvl++ {
+		p[lvl] = ((1 << depth) + leaf) >> (depth - lvl)
+	}
+	return p
+}
+
+// Moves every block on the path to [leaf] out of the tree and returns them
+func (t *bucketTree) readPath(leaf int) []slot {
+	var out []slot
+	for _, n := range t.path(leaf) {
+		out = append(out, t.buckets[n]...)
+		t.buckets[n] = nil
+	}
+	return out
+}
+
+// Greedy Path ORAM write-back: fills the path to [leaf] from the leaf upwards,
+// placing each block of [pending] as deep as its own path allows.
+// Returns the blocks that did not fit (these stay in the stash).
+func (t *bucketTree) writePath(leaf int, pending []slot) []slot {
+	for lvl := t.depth; lvl >= 0; lvl-- {
+		n := t.node(leaf, lvl)
+		rest := pending[:0:0]
+		for _, s := range pending {
+			if len(t.buckets[n]) < t.z && t.node(s.a.leaf, lvl) == n {
+				t.buckets[n] = append(t.buckets[n], s)
+			} else {
+				rest = append(rest, s)
+			}
+		}
+		pending = rest
+	}
+	return pending
+}
+
+// Level of the deepest bucket shared by the paths to leaves [x] and [y] (their LCA)
+func (t *bucketTree) commonDepth(x, y int) int {
+	lvl := t.depth
+	for t.node(x, lvl) != t.node(y, lvl) {
+		lvl--
+	}
+	return lvl
+}
+
+// The [g]-th leaf in reverse-lexicographic order (the bits of g mod 2^depth, reversed),
+// used to pick eviction paths deterministically
+func (t *bucketTree) reverseLexLeaf(g int) int {
+	g %= 1 << t.depth
+	leaf := 0
+	for b := 0; b < t.depth; b++ {
+		leaf = (leaf << 1) | ((g >> b) & 1)
+	}
+	return leaf
+}
+
+// ------------ Encrypted / authenticated storage ------------ //
+
+// From now on paths must be opened before use and closed afterwards. The buckets are sealed
+// on the first access, so that their nonces come from the rng the OSAM sets (see setRand),
+// which is usually set after encryption is enabled.
+func (t *bucketTree) enableEncryption(key []byte) error {
+	sl, err := createSealer(key)
+	if err != nil {
+		return err
+	}
+	t.sealer = sl
+	t.sealed = make([][]byte, len(t.buckets))
+	t.sealPending = true
+	return nil
+}
+
+// Seals every bucket if encryption was enabled since the last access
+func (t *bucketTree) sealAll() {
+	if !t.sealPending {
+		return
+	}
+	t.sealPending = false
+	for n := 1; n < len(t.buckets); n++ {
+		t.seal(n)
+	}
+	if t.merkle != nil {
+		t.rehashAll()
+	}
+}
+
+// Fetches, verifies and decrypts bucket [n] from the server
+func (t *bucketTree) openBucket(n int) error {
+	t.sealAll()
+	t.rec.record(t.id, OpRead, NONE, []int{n})
+	if err := t.verifyNode(n); err != nil {
+		return err
+	}
+	return t.unseal(n)
+}
+
+// Re-encrypts bucket [n] and sends it back to the server
+func (t *bucketTree) closeBucket(n int) {
+	t.rec.record(t.id, OpWrite, NONE, []int{n})
+	t.seal(n)
+	t.updateNode(n)
+}
+
+// Same as [openBucket] for every bucket on the path to [leaf], verified in one pass
+func (t *bucketTree) openPath(leaf int) error {
+	t.sealAll()
+	t.rec.record(t.id, OpRead, leaf, t.path(leaf))
+	if err := t.verifyNode(t.node(leaf, t.depth)); err != nil {
+		return err
+	}
+	for _, n := range t.path(leaf) {
+		if err := t.unseal(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Online read of one slot per bucket (Ring ORAM): verifies the path to [leaf] and returns the
+// blocks of its buckets, root first, without changing what the server stores, so nothing is
+// re-sealed or rehashed
+func (t *bucketTree) peekPath(leaf int) ([][]slot, error) {
+	t.sealAll()
+	t.rec.record(t.id, OpRead, leaf, t.path(leaf))
+	if err := t.verifyNode(t.node(leaf, t.depth)); err != nil {
+		return nil, err
+	}
+	out := make([][]slot, 0, t.depth+1)
+	for _, n := range t.path(leaf) {
+		if t.sealer == nil && t.merkle == nil {
+			out = append(out, t.buckets[n])
+			continue
+		}
+		var slots []slot
+		var err error
+		if t.sealer != nil {
+			slots, err = t.sealer.peek(n, t.sealed[n], t.z)
+		} else {
+			// the blocks stay in the hashed bucket: hand out copies, or changes the client makes to
+			// a node it read would change the server's bucket too
+			slots, err = decodeBucket(encodeBucket(t.buckets[n], t.z))
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, slots)
+	}
+	return out, nil
+}
+
+func (t *bucketTree) closePath(leaf int) {
+	t.rec.record(t.id, OpWrite, leaf, t.path(leaf))
+	for _, n := range t.path(leaf) {
+		t.seal(n)
+	}
+	t.updateNode(t.node(leaf, t.depth))
+}
+
+// Decrypts bucket [n] into [buckets] (no-op without encryption)
+func (t *bucketTree) unseal(n int) error {
+	if t.sealer == nil {
+		return nil
+	}
+	slots, err := t.sealer.open(n, t.sealed[n])
+	if err != nil {
+		return err
+	}
+	t.buckets[n] = slots
+	return nil
+}
+
+// Encrypts bucket [n] under a fresh nonce, dropping the plaintext (no-op without encryption)
+func (t *bucketTree) seal(n int) {
+	if t.sealer == nil {
+		return
+	}
+	ct := t.sealer.seal(n, t.buckets[n], t.z, t.rng)
+	t.serverBytes += len(ct) - len(t.sealed[n])
+	t.sealed[n] = ct
+	t.buckets[n] = nil
+}
+
+func (t *bucketTree) addCryptoStats(s *ORAMStats) {
+	if t.merkle != nil {
+		s.Hashes += t.merkle.count
+	}
+	if t.sealer == nil {
+		return
+	}
+	s.Encryptions += t.sealer.encryptions
+	s.BytesRead += t.sealer.bytesOpened
+	s.BytesWritten += t.sealer.bytesSealed
+	s.ServerBytes += t.serverBytes
+}
@@ -0,0 +1,254 @@
+package osam_simulator
+
+import "math/rand"
+
+// ------------- Circuit ORAM ------------- //
+// Based on Wang, Chan, Shi, "Circuit ORAM" (CCS 2015).
+// An access only removes the requested block from its path; blocks are then pushed down by
+// two evictions per access, along paths chosen in reverse-lexicographic order.
+// Each eviction is a single root-to-leaf pass that holds at most one block at a time,
+// which is what keeps the client circuit small.
+
+// Bucket capacity Z used by CircuitORAM
+const CircuitBucketSize = 2
+
+// Number of evictions performed per access
+const circuitEvictions = 2
+
+type CircuitORAM struct {
+	nl     int
+	logger *Logger
+	stats  ORAMStats
+
+	tree      *bucketTree
+	stash     []slot
+	stashLog  *stashTracker
+	evictCtr  int  // next eviction path, taken in reverse-lexicographic order
+	accessing bool // a [readRmAccess] is waiting for its [evict]
+}
+
+func CreateCircuitORAM(nleaves int) *CircuitORAM {
+	return &CircuitORAM{nl: nleaves,
+		tree: createBucketTree(nleaves, CircuitBucketSize), stashLog: createStashTracker()}
+}
+
+func (oram *CircuitORAM) logf(format string, args ...interface{}) {
+	oram.logger.logf(TagORAM, LevelDebug, format, args...)
+}
+
+func (oram *CircuitORAM) numLeaves() int {
+	return oram.nl
+}
+
+func (oram *CircuitORAM) setRand(rng *rand.Rand) {
+	oram.tree.rng = rng
+}
+
+func (oram *CircuitORAM) setLogger(l *Logger) {
+	oram.logger = l
+	oram.stashLog.logger = l
+}
+
+func (oram *CircuitORAM) setRecorder(rec *Transcript) {
+	oram.tree.rec = rec
+}
+
+func (oram *CircuitORAM) Stats() ORAMStats {
+	out := oram.stats
+	out.StashMax = oram.stashLog.stats.Max
+	oram.tree.addCryptoStats(&out)
+	return out
+}
+
+// Same semantics as PathORAM.EnableEncryption
+func (oram *CircuitORAM) EnableEncryption(key []byte) error {
+	return oram.tree.enableEncryption(key)
+}
+
+// Same semantics as PathORAM.EnableIntegrity
+func (oram *CircuitORAM) EnableIntegrity() {
+	oram.tree.enableIntegrity()
+}
+
+// Same semantics as PathORAM.SetStashBound
+func (oram *CircuitORAM) SetStashBound(bound int, policy StashOverflowPolicy) {
+	oram.stashLog.bound = bound
+	oram.stashLog.policy = policy
+}
+
+// Same semantics as PathORAM.RecordStashSeries
+func (oram *CircuitORAM) RecordStashSeries() {
+	oram.stashLog.series = true
+}
+
+func (oram *CircuitORAM) StashStats() StashStats {
+	return oram.stashLog.snapshot()
+}
+
+// Reads the path to [a.leaf] and the stash, removing only the block at [a]
+func (oram *CircuitORAM) readRmAccess(a addr, callerMsg string) (Block, error) {
+	i := a.leaf
+	if i < 0 || i >= oram.nl {
+		return Block{}, leafErr(i, oram.nl)
+	}
+	if oram.accessing {
+		// previous access was never closed
+		if err := oram.evict(); err != nil {
+			return Block{}, err
+		}
+	}
+	oram.accessing = true
+	oram.logf("ReadAndRm ACCESS: %v, called from: %v", a, callerMsg)
+	oram.stats.Accesses++
+	oram.stats.BlocksRead += (oram.tree.depth + 1) * oram.tree.z
+	oram.stats.BlocksWritten += (oram.tree.depth + 1) * oram.tree.z
+
+	if err := oram.tree.openPath(i); err != nil {
+		return Block{}, err
+	}
+	defer oram.tree.closePath(i)
+	if v, ok := takeSlot(&oram.stash, a); ok {
+		return v, nil
+	}
+	for _, n := range oram.tree.path(i) {
+		if v, ok := takeSlot(&oram.tree.buckets[n], a); ok {
+			return v, nil
+		}
+	}
+	oram.logf("Read yielded None when reading %v", a)
+	return Block{Data: NONE, IsNone: true}, nil
+}
+
+// Removes the block at [a] from [slots], if present
+func takeSlot(slots *[]slot, a addr) (Block, bool) {
+	for j, s := range *slots {
+		if s.a == a {
+			*slots = append((*slots)[:j], (*slots)[j+1:]...)
+			return s.b, true
+		}
+	}
+	return Block{}, false
+}
+
+// Finishes the access with two evictions
+func (oram *CircuitORAM) evict() error {
+	if !oram.accessing {
+		return nil
+	}
+	oram.accessing = false
+	for k := 0; k < circuitEvictions; k++ {
+		if err := oram.evictPath(oram.nextEvictLeaf()); err != nil {
+			return err
+		}
+	}
+	return oram.stashLog.record(len(oram.stash))
+}
+
+func (oram *CircuitORAM) evictWrite(a addr, value interface{}) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	oram.logf("Evict=Write: storing value %v at %v", value, a)
+	oram.stash = append(oram.stash, slot{a, Block{value, false}})
+	return oram.evict()
+}
+
+func (oram *CircuitORAM) evictDummy(a addr) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	return oram.evict()
+}
+
+func (oram *CircuitORAM) nextEvictLeaf() int {
+	leaf := oram.tree.reverseLexLeaf(oram.evictCtr)
+	oram.evictCtr++
+	return leaf
+}
+
+// ------------ Circuit ORAM eviction ------------ //
+// Positions on the eviction path are numbered 0 = stash, k = bucket at level k-1.
+
+func (oram *CircuitORAM) evictPath(leaf int) error {
+	t := oram.tree
+	n := t.depth + 2
+	bucketAt := func(k int) *[]slot {
+		if k == 0 {
+			return &oram.stash
+		}
+		return &t.buckets[t.node(leaf, k-1)]
+	}
+	// Position that the block of [slots] which can go deepest along the path can reach
+	deepestIn := func(slots []slot) (int, int) {
+		best, bestPos := NONE, NONE
+		for j, s := range slots {
+			if pos := t.commonDepth(s.a.leaf, leaf) + 1; pos > bestPos {
+				best, bestPos = j, pos
+			}
+		}
+		return best, bestPos
+	}
+	oram.stats.BlocksRead += (t.depth + 1) * t.z
+	oram.stats.BlocksWritten += (t.depth + 1) * t.z
+	if err := t.openPath(leaf); err != nil {
+		return err
+	}
+	defer t.closePath(leaf)
+
+	// PrepareDeepest: deepest[k] = position above k holding the block that can go deepest
+	deepest := make([]int, n)
+	src, goal := NONE, NONE
+	for k := 0; k < n; k++ {
+		deepest[k] = NONE
+		if goal >= k {
+			deepest[k] = src
+		}
+		if _, pos := deepestIn(*bucketAt(k)); pos > goal {
+			goal = pos
+			src = k
+		}
+	}
+
+	// PrepareTarget: target[k] = position the block picked up at k should be dropped at
+	target := make([]int, n)
+	dest := NONE
+	src = NONE
+	for k := n - 1; k >= 0; k-- {
+		target[k] = NONE
+		if k == src {
+			target[k] = dest
+			dest = NONE
+			src = NONE
+		}
+		hasRoom := k > 0 && len(*bucketAt(k)) < t.z
+		if ((dest == NONE && hasRoom) || target[k] != NONE) && deepest[k] != NONE {
+			src = deepest[k]
+			dest = k
+		}
+	}
+
+	// EvictOnceFast: one pass from the stash down, holding at most one block
+	var hold *slot
+	dest = NONE
+	for k := 0; k < n; k++ {
+		var toWrite *slot
+		if hold != nil && k == dest {
+			toWrite = hold
+			hold = nil
+			dest = NONE
+		}
+		if target[k] != NONE {
+			b := bucketAt(k)
+			j, _ := deepestIn(*b)
+			s := (*b)[j]
+			*b = append((*b)[:j], (*b)[j+1:]...)
+			hold = &s
+			dest = target[k]
+		}
+		if toWrite != nil {
+			*bucketAt(k) = append(*bucketAt(k), *toWrite)
+		}
+	}
+	assert(hold == nil, "Circuit ORAM eviction ended while holding a block")
+	return nil
+}
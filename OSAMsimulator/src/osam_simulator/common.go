@@ -1,6 +1,7 @@
 package osam_simulator
 
 import (
+	"fmt"
 	"strconv"
 )
 
@@ -15,17 +16,6 @@ func hasAddr(m map[addr]bool, a addr) bool {
 	return ok
 }
 
-// for suppressing / un-supressing all print output
-var suppressPrint = false
-
-func Suppress() {
-	suppressPrint = true
-}
-
-func Unsupress() {
-	suppressPrint = false
-}
-
 // ------------ BLOCK ------------
 // INVARIANT for simulation: only use non-negative integers as values, so -1 = NONE
 // type val = int
@@ -36,6 +26,22 @@ type Block struct {
 	IsNone bool
 }
 
+// Block holding [v]
+func Some[T any](v T) Block {
+	return Block{Data: v, IsNone: false}
+}
+
+// Payload of [b] as a T; the zero T if [b] is None
+func blockAs[T any](b Block) T {
+	var zero T
+	if b.IsNone {
+		return zero
+	}
+	v, ok := b.Data.(T)
+	assert(ok, fmt.Sprintf("block holds %T, not %T", b.Data, zero))
+	return v
+}
+
 // ------------ ADDR ------------
 // Based on OSAM paper: addresses include a global unique id "ctr" and the leaf index "leaf"
 // which is printed as "ctr_leaf"
@@ -55,6 +61,20 @@ type Ptr struct {
 	head addr
 }
 
+// ------------ SmartPointerAPI (implemented by SmartPointer and BSP) ------------
+// Pointer operations take *Ptr because Get/Put/Copy move the pointer's queue head.
+type SmartPointerAPI interface {
+	New(c Block) Ptr
+	Copy(p1 *Ptr) Ptr
+	Get(p *Ptr) Block
+	Put(p *Ptr, c Block)
+	Delete(p *Ptr)
+	IsNull(p *Ptr) bool
+}
+
+var _ SmartPointerAPI = (*SmartPointer)(nil)
+var _ SmartPointerAPI = (*BSP)(nil)
+
 // ------------ Node (for base SmartPointers) ------------
 type Node struct {
 	tailL   addr
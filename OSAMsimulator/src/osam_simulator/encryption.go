@@ -0,0 +1,255 @@
+package osam_simulator
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+)
+
+// ------------- Bucket encryption ------------- //
+// Optional layer for the tree-based ORAMs: every bucket is serialized (padded to Z slots with
+// dummies) and sealed with AES-GCM under a client key, with a fresh nonce on every write-back.
+// The server only ever holds these ciphertexts. The bucket index is bound in as associated data,
+// so the server cannot swap buckets either.
+// NOTE: payload types stored in Block.Data (other than basic types) must be registered with gob.
+
+func init() {
+	gob.Register(&Node{})
+	gob.Register(&BNode{})
+	gob.Register(QueueElem{})
+	gob.Register(Vtx{})
+	gob.Register(bfsItem{})
+}
+
+// Raised when a bucket fails to authenticate or decode
+type IntegrityError struct {
+	Bucket int
+	Reason string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed for bucket %v: %v", e.Bucket, e.Reason)
+}
+
+type sealer struct {
+	aead        cipher.AEAD
+	encryptions int
+	decryptions int
+	bytesSealed int // ciphertext bytes written to the server
+	bytesOpened int // ciphertext bytes read from the server
+}
+
+// [key] must be 16, 24 or 32 bytes (AES-128/192/256)
+func createSealer(key []byte) (*sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+func bucketAD(n int) []byte {
+	ad := make([]byte, 8)
+	binary.BigEndian.PutUint64(ad, uint64(n))
+	return ad
+}
+
+// Returns nonce || ciphertext of bucket [n], with the nonce drawn from [rng]
+func (s *sealer) seal(n int, slots []slot, z int, rng *rand.Rand) []byte {
+	nonce := make([]byte, s.aead.NonceSize())
+	randBytes(rng, nonce)
+	ct := s.aead.Seal(nonce, nonce, encodeBucket(slots, z), bucketAD(n))
+	s.encryptions++
+	s.bytesSealed += len(ct)
+	return ct
+}
+
+func (s *sealer) open(n int, ct []byte) ([]slot, error) {
+	s.decryptions++
+	s.bytesOpened += len(ct)
+	ns := s.aead.NonceSize()
+	if len(ct) < ns {
+		return nil, &IntegrityError{n, "ciphertext too short"}
+	}
+	pt, err := s.aead.Open(nil, ct[:ns], ct[ns:], bucketAD(n))
+	if err != nil {
+		return nil, &IntegrityError{n, err.Error()}
+	}
+	slots, err := decodeBucket(pt)
+	if err != nil {
+		return nil, &IntegrityError{n, err.Error()}
+	}
+	return slots, nil
+}
+
+// [open] for an online read that fetches a single slot: only that slot's share of the
+// ciphertext counts as read
+func (s *sealer) peek(n int, ct []byte, z int) ([]slot, error) {
+	slots, err := s.open(n, ct)
+	s.bytesOpened -= len(ct) - len(ct)/z
+	return slots, err
+}
+
+// ------------ Serialization ------------ //
+
+type wireSlot struct {
+	Addr   [2]int // {ctr, leaf}; ctr == NONE marks a dummy
+	Data   interface{}
+	IsNone bool
+}
+
+func (a addr) wire() [2]int {
+	return [2]int{a.ctr, a.leaf}
+}
+
+func addrFromWire(w [2]int) addr {
+	return addr{w[0], w[1]}
+}
+
+// Serializes a bucket, padded with dummies to [z] slots so every bucket has the same shape
+func encodeBucket(slots []slot, z int) []byte {
+	wire := make([]wireSlot, 0, z)
+	for _, s := range slots {
+		wire = append(wire, wireSlot{s.a.wire(), s.b.Data, s.b.IsNone})
+	}
+	for len(wire) < z {
+		wire = append(wire, wireSlot{Addr: NIL.wire(), Data: NONE, IsNone: true})
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wire); err != nil {
+		panic(fmt.Sprintf("cannot serialize bucket: %v", err))
+	}
+	return buf.Bytes()
+}
+
+func decodeBucket(pt []byte) ([]slot, error) {
+	var wire []wireSlot
+	if err := gob.NewDecoder(bytes.NewReader(pt)).Decode(&wire); err != nil {
+		return nil, err
+	}
+	var slots []slot
+	for _, w := range wire {
+		if w.Addr[0] != NONE {
+			slots = append(slots, slot{addrFromWire(w.Addr), Block{w.Data, w.IsNone}})
+		}
+	}
+	return slots, nil
+}
+
+// gob needs exported fields, so the node types go through these wire structs
+
+type wireNode struct {
+	TailL, TailR, HeadP [2]int
+	IsRoot              bool
+	Content             Block
+	Id                  int
+}
+
+func (nd *Node) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(wireNode{nd.tailL.wire(), nd.tailR.wire(), nd.headP.wire(),
+		nd.isRoot, nd.content, nd.id})
+	return buf.Bytes(), err
+}
+
+func (nd *Node) GobDecode(b []byte) error {
+	var w wireNode
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+	*nd = Node{tailL: addrFromWire(w.TailL), tailR: addrFromWire(w.TailR), headP: addrFromWire(w.HeadP),
+		isRoot: w.IsRoot, content: w.Content, id: w.Id}
+	return nil
+}
+
+type wireBNode struct {
+	TailL, HeadL, TailR, HeadR, HeadP, TailP [2]int
+	IsRoot                                   bool
+	Content                                  Block
+	Count                                    int
+	Id                                       int
+}
+
+func (nd *BNode) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(wireBNode{nd.tailL.wire(), nd.headL.wire(), nd.tailR.wire(),
+		nd.headR.wire(), nd.headP.wire(), nd.tailP.wire(), nd.isRoot, nd.content, nd.count, nd.id})
+	return buf.Bytes(), err
+}
+
+func (nd *BNode) GobDecode(b []byte) error {
+	var w wireBNode
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+	*nd = BNode{tailL: addrFromWire(w.TailL), headL: addrFromWire(w.HeadL), tailR: addrFromWire(w.TailR),
+		headR: addrFromWire(w.HeadR), headP: addrFromWire(w.HeadP), tailP: addrFromWire(w.TailP),
+		isRoot: w.IsRoot, content: w.Content, count: w.Count, id: w.Id}
+	return nil
+}
+
+func (qe QueueElem) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode([2][2]int{qe.v.wire(), qe.link.wire()})
+	return buf.Bytes(), err
+}
+
+func (qe *QueueElem) GobDecode(b []byte) error {
+	var w [2][2]int
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+	*qe = QueueElem{v: addrFromWire(w[0]), link: addrFromWire(w[1])}
+	return nil
+}
+
+type wireVtx struct {
+	Id, Type          int
+	Other, UP, LC, RC [2]int
+}
+
+func (v Vtx) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(wireVtx{v.Id, v.Type, v.Other.wire(), v.UP.wire(), v.LC.wire(), v.RC.wire()})
+	return buf.Bytes(), err
+}
+
+func (v *Vtx) GobDecode(b []byte) error {
+	var w wireVtx
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+	*v = Vtx{Id: w.Id, Type: w.Type, Other: addrFromWire(w.Other), UP: addrFromWire(w.UP),
+		LC: addrFromWire(w.LC), RC: addrFromWire(w.RC)}
+	return nil
+}
+
+type wireBFSItem struct {
+	Key, From, Slot, Dist int
+	Vtx                   Vtx
+	Next                  [2]int
+}
+
+func (it bfsItem) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	m := it.msg
+	err := gob.NewEncoder(&buf).Encode(wireBFSItem{m.key, m.from, m.slot, m.dist, m.vtx, it.next.wire()})
+	return buf.Bytes(), err
+}
+
+func (it *bfsItem) GobDecode(b []byte) error {
+	var w wireBFSItem
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+	*it = bfsItem{bfsMsg{key: w.Key, from: w.From, slot: w.Slot, dist: w.Dist, vtx: w.Vtx}, addrFromWire(w.Next)}
+	return nil
+}
@@ -0,0 +1,33 @@
+package osam_simulator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ------------- Errors ------------- //
+// Misuse of the OSAM is reported with these sentinels, wrapped with the offending address;
+// test for them with errors.Is. Tampering detected by an ORAM surfaces as *IntegrityError,
+// a stash grown past its bound under StashOverflowFail as ErrStashOverflow.
+// In strict mode (see OSAM.SetStrict) the same errors are raised as panics instead.
+// SmartPointer and BSP have no error results: an error from the OSAM underneath them always
+// means a broken pointer structure (e.g. a stale copy of a Ptr), so they panic with it.
+
+var (
+	ErrDoubleRead     = errors.New("address has already been read")
+	ErrDoubleWrite    = errors.New("address has already been written to")
+	ErrNotAllocated   = errors.New("address has not been alloc'd")
+	ErrLeafOutOfRange = errors.New("leaf index out of range")
+	ErrOutOfCapacity  = errors.New("block id exceeds the ORAM capacity")
+	ErrMalformedGraph = errors.New("emulated graph is malformed")
+	ErrStashOverflow  = errors.New("stash overflow")
+	ErrUnknownMode    = errors.New("unknown ORAM mode")
+)
+
+func addrErr(err error, a addr) error {
+	return fmt.Errorf("%w: %v", err, a)
+}
+
+func leafErr(leaf, nleaves int) error {
+	return fmt.Errorf("%w: i=%v, n=%v", ErrLeafOutOfRange, leaf, nleaves)
+}
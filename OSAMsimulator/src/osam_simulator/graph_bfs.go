@@ -0,0 +1,322 @@
+package osam_simulator
+
+// ------------- Oblivious BFS over the emulated graph ------------- //
+// Synchronous relaxation over the vertex records of an OSAMGraph, in rounds of sort-and-scan.
+// Every record sends one message carrying itself and its distance, plus two requests for the
+// distance of the records it links to (by their address), and takes:
+//   - Real vertex: its in-tree root's distance (LC)
+//   - in-tree internal node: the smaller of its children's (LC, RC)
+//   - Inc leaf: its Out leaf's, plus one (Other: crossing an edge of the input graph)
+//   - Out leaf, out-tree internal node: its parent's (UP)
+// A round O-sorts the messages by the record asked about, answers the requests in a linear scan,
+// O-sorts them back to their records, and relaxes in a second scan that also sends the messages
+// of the next round.
+//
+// The messages never leave the OSAM: they are a linked list of N = 2^lg(3 MaxVertices) items
+// (padded), sorted with the shuffle bitonic sort (see osort.go), where each compare-exchange reads
+// two items and writes them back as the next two items of a new list, and each scan reads one item
+// and writes one. The client only keeps a few list cursors and the message being relaxed.
+// Records are padded to MaxVertices and the number of rounds is fixed by V and E, so the accesses
+// depend on nothing else.
+//
+// Cost: bfsRounds() = (V-1)(3+2 lg E) rounds, each 2 (N/2) lg^2 N compare-exchanges and 2N scan
+// steps, 2 OSAM reads and 2 writes per compare-exchange and 1 + 1 per scan step. A star or a path
+// on 6 vertices (E = 5, MaxVertices = 26, N = 128) takes 45 rounds and 282,240 compare-exchanges,
+// i.e. about 1.15 million OSAM accesses.
+
+const bfsInf = int(^uint(0) >> 2) // unreached; room to add 1
+
+const (
+	bfsSlots   = 2            // requests per record
+	bfsPerVtx  = bfsSlots + 1 // messages per record
+	bfsValueOf = NONE         // slot of the message carrying the record
+)
+
+type BFSResult struct {
+	Dist             map[int]int // edges from the source to each Real vertex (NONE = unreachable)
+	Rounds           int
+	CompareExchanges int
+}
+
+type bfsMsg struct {
+	key  int // address counter of the record whose distance is asked for / given; NONE for padding records
+	from int // index of the sending record
+	slot int // which of its requests, or bfsValueOf
+	dist int
+	vtx  Vtx // the sending record (value messages only)
+}
+
+// Pads the list to a power of 2: sorts after every message
+var bfsPad = bfsMsg{key: bfsInf, from: bfsInf, dist: bfsInf}
+
+// Padding record: no links and no type, so it stays unreached
+var bfsPadVtx = Vtx{Id: NONE, Type: NONE, Other: NIL, UP: NIL, LC: NIL, RC: NIL}
+
+func (m *bfsMsg) isValue() bool {
+	return m.slot == bfsValueOf
+}
+
+// By asked record, its value message first
+func byKey(a, b *bfsMsg) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.isValue() && !b.isValue()
+}
+
+// By sending record, its value message first
+func byRequester(a, b *bfsMsg) bool {
+	if a.from != b.from {
+		return a.from < b.from
+	}
+	return a.slot < b.slot
+}
+
+// The records whose distances [v] needs, in slot order
+func bfsLinks(v Vtx) [bfsSlots]ptr {
+	switch v.Type {
+	case Real:
+		return [bfsSlots]ptr{v.LC, NIL}
+	case Internal:
+		return [bfsSlots]ptr{v.LC, v.RC}
+	case Inc:
+		return [bfsSlots]ptr{v.Other, NIL}
+	case Out, OutInternal:
+		return [bfsSlots]ptr{v.UP, NIL}
+	}
+	return [bfsSlots]ptr{NIL, NIL}
+}
+
+// New distance of [v], given the answers to its requests
+func bfsRelax(v Vtx, dist int, got [bfsSlots]int) int {
+	cand := bfsInf
+	switch v.Type {
+	case Real, Out, OutInternal:
+		cand = got[0]
+	case Internal:
+		cand = minInt(got[0], got[1])
+	case Inc:
+		cand = got[0] + 1
+	}
+	return minInt(dist, cand)
+}
+
+// Number of rounds after which every distance is final: a shortest path crosses at most V-1
+// edges, and crossing one is at most lg(E) steps down an out-tree and up an in-tree, plus the
+// steps from the Real vertex, to the Inc leaf, and to the next Real vertex
+func (oG *OSAMGraph) bfsRounds() int {
+	if oG.nV <= 1 || oG.nE == 0 {
+		return 0
+	}
+	return (oG.nV - 1) * (3 + 2*lg(oG.nE))
+}
+
+// Distances from the Real vertex with Id [source]. Reads every vertex of the graph once, so it
+// consumes the graph's OSAM blocks (like Load: a graph can do one or the other).
+func (oG *OSAMGraph) BFS(source int) (BFSResult, error) {
+	defer oG.osam.lock()()
+	defer oG.osam.track("IG.bfs")()
+	io := &bfsIO{osam: oG.osam}
+	out := BFSResult{Rounds: oG.bfsRounds()}
+
+	// 1. every record's messages, read from the graph: padding records for the missing vertices,
+	// then padding messages
+	n := nextPowTwo(bfsPerVtx * oG.MaxVertices())
+	w := io.newList(n)
+	for i := 0; i < oG.MaxVertices(); i++ {
+		m := bfsMsg{key: NONE, from: i, slot: bfsValueOf, dist: bfsInf, vtx: bfsPadVtx}
+		if i < len(oG.addrs) {
+			b, err := oG.osam.read(oG.addrs[i])
+			if err != nil {
+				return BFSResult{}, err
+			}
+			m.key, m.vtx = oG.addrs[i].ctr, blockAs[Vtx](b)
+			if m.vtx.Type == Real && m.vtx.Id == source {
+				m.dist = 0
+			}
+		} else if err := oG.osam.dummyRead("BFS padding"); err != nil {
+			return BFSResult{}, err
+		}
+		w.putRecord(m)
+	}
+	for q := bfsPerVtx * oG.MaxVertices(); q < n; q++ {
+		w.put(bfsPad)
+	}
+	msgs := w.list
+
+	for r := 0; r < out.Rounds && io.err == nil; r++ {
+		// 2. O-SORT by asked record, LINEAR-SCAN to answer
+		var ops int
+		msgs, ops = io.sort(msgs, byKey)
+		out.CompareExchanges += ops
+		key, dist := NONE, bfsInf
+		msgs = io.scan(msgs, func(q int, m bfsMsg) bfsMsg {
+			if m.isValue() {
+				key, dist = m.key, m.dist
+			} else if m.key == key && key != NONE {
+				m.dist = dist
+			}
+			return m
+		})
+
+		// 3. O-SORT back, LINEAR-SCAN to relax: record i is at [3i, 3i+3), its value message first.
+		// Its next requests go out as its answers come in, its value message last.
+		msgs, ops = io.sort(msgs, byRequester)
+		out.CompareExchanges += ops
+		var rec bfsMsg
+		var got [bfsSlots]int
+		msgs = io.scan(msgs, func(q int, m bfsMsg) bfsMsg {
+			if q >= bfsPerVtx*oG.MaxVertices() {
+				return bfsPad
+			}
+			s := q % bfsPerVtx
+			if s == 0 {
+				rec = m
+			} else {
+				got[s-1] = m.dist
+			}
+			if s < bfsSlots {
+				return bfsRequest(&rec, s)
+			}
+			rec.dist = bfsRelax(rec.vtx, rec.dist, got)
+			return rec
+		})
+	}
+
+	// 4. LINEAR-SCAN for the distances of the Real vertices
+	out.Dist = make(map[int]int, oG.nV)
+	at := msgs.head
+	for q := 0; q < msgs.n; q++ {
+		var m bfsMsg
+		m, at = io.read(at)
+		if m.isValue() && m.key != NONE && m.vtx.Type == Real {
+			out.Dist[m.vtx.Id] = NONE
+			if m.dist < bfsInf {
+				out.Dist[m.vtx.Id] = m.dist
+			}
+		}
+	}
+	if io.err != nil {
+		return BFSResult{}, io.err
+	}
+	oG.logf(LevelInfo, "BFS from %v: %v rounds, %v compare-exchanges", source, out.Rounds, out.CompareExchanges)
+	return out, nil
+}
+
+// Request [s] of the record carried by value message [rec]
+func bfsRequest(rec *bfsMsg, s int) bfsMsg {
+	return bfsMsg{key: bfsLinks(rec.vtx)[s].ctr, from: rec.from, slot: s, dist: bfsInf, vtx: bfsPadVtx}
+}
+
+// ------------- Message lists in the OSAM ------------- //
+
+// A list of [n] messages: each item holds the address of the next
+type bfsList struct {
+	head, mid ptr // items 0 and n/2
+	n         int
+}
+
+type bfsItem struct {
+	msg  bfsMsg
+	next ptr
+}
+
+// Reads and writes the lists of one BFS. The first error sticks: later reads give padding and
+// later writes are dropped, and BFS returns it at the end.
+type bfsIO struct {
+	osam *OSAM
+	err  error
+}
+
+// Reads the item at [at]: its message and the address of the next item
+func (io *bfsIO) read(at ptr) (bfsMsg, ptr) {
+	if io.err != nil {
+		return bfsPad, NIL
+	}
+	b, err := io.osam.read(at)
+	if err != nil {
+		io.err = err
+		return bfsPad, NIL
+	}
+	item := blockAs[bfsItem](b)
+	return item.msg, item.next
+}
+
+// Writes a new list of [n] messages in order
+type bfsWriter struct {
+	io   *bfsIO
+	list bfsList
+	next ptr // address of the next item
+	done int
+}
+
+func (io *bfsIO) newList(n int) *bfsWriter {
+	w := &bfsWriter{io: io, list: bfsList{n: n}, next: io.osam.alloc("BFS list")}
+	w.list.head = w.next
+	return w
+}
+
+func (w *bfsWriter) put(m bfsMsg) {
+	at := w.next
+	if w.done == w.list.n/2 {
+		w.list.mid = at
+	}
+	w.done++
+	w.next = NIL
+	if w.done < w.list.n {
+		w.next = w.io.osam.alloc("BFS list")
+	}
+	if w.io.err == nil {
+		w.io.err = w.io.osam.write(at, bfsItem{m, w.next}, "BFS message")
+	}
+}
+
+// The messages of the record carried by value message [rec], in the order of a relax scan
+func (w *bfsWriter) putRecord(rec bfsMsg) {
+	for s := 0; s < bfsSlots; s++ {
+		w.put(bfsRequest(&rec, s))
+	}
+	w.put(rec)
+}
+
+// Shuffle bitonic sort of [l] by [less] (see osort.go); returns the sorted list and the number
+// of compare-exchanges
+func (io *bfsIO) sort(l bfsList, less func(a, b *bfsMsg) bool) (bfsList, int) {
+	d := lg(l.n)
+	for s := 0; s < d*d; s++ {
+		w := io.newList(l.n)
+		lo, hi := l.head, l.mid
+		for p := 0; p < l.n/2; p++ {
+			var x, y bfsMsg
+			x, lo = io.read(lo)
+			y, hi = io.read(hi)
+			dir := shuffleDir(d, s, p)
+			if (dir == 1 && less(&y, &x)) || (dir == -1 && less(&x, &y)) {
+				x, y = y, x
+			}
+			w.put(x)
+			w.put(y)
+		}
+		l = w.list
+	}
+	return l, ShuffleBitonicOps(l.n)
+}
+
+// Streams [l] into a new list, one item read and one written at a time: item [q] becomes f(q, m)
+func (io *bfsIO) scan(l bfsList, f func(q int, m bfsMsg) bfsMsg) bfsList {
+	w := io.newList(l.n)
+	at := l.head
+	for q := 0; q < l.n; q++ {
+		var m bfsMsg
+		m, at = io.read(at)
+		w.put(f(q, m))
+	}
+	return w.list
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -9,12 +9,14 @@ import (
 )
 
 // ** Current notes ** :
-//  - not actually using OSAM right now, dummy implementation for PoC
-//  - no actual dummy operations happening
+//  - every vertex is written to the OSAM exactly once, when all its links are known; until then
+//    it waits in a client-side pending buffer, which stays O(log E) (see [flushComplete])
+//  - every iteration of the streaming passes pads its allocations and writes with dummy ones up to
+//    [stepAllocs] / [stepWrites], so vertex and edge entries look the same to the server
 
 // -------- TYPE DEFINITIONS --------- //
 
-type ptr = int
+type ptr = addr
 
 type VtxType = int
 
@@ -22,7 +24,8 @@ const (
 	Real VtxType = iota
 	Inc
 	Out
-	Internal
+	Internal    // internal node of an in-tree
+	OutInternal // internal node of an out-tree
 )
 
 type InputEdge struct {
@@ -32,17 +35,18 @@ type InputEdge struct {
 }
 
 type InputGraph struct {
-	print bool
-	edges []InputEdge
+	edges      []InputEdge
+	cmpEx      int  // compare-exchanges done by the O-sorts so far
+	checkSteps bool // see SetCheckSteps
 }
 
 type Vtx struct {
 	Id    int // Real vertex that this is associated with
 	Type  VtxType
-	Other int // If VtxType = Inc or Out: Other = other part of this edge
+	Other ptr // If VtxType = Inc or Out: Other = other part of this edge
 	UP    ptr
-	LC    ptr
-	RC    ptr
+	LC    ptr // Real vertex: root of its in-tree
+	RC    ptr // Real vertex: root of its out-tree
 }
 
 type Edge struct {
@@ -51,13 +55,27 @@ type Edge struct {
 }
 
 type OSAMGraph struct {
-	print   bool
-	pCtr    ptr // global counter for assigning dummy "pointers" = ptr
-	in_deg  []int
-	out_deg []int
+	osam        *OSAM
+	addrs       []ptr        // every vertex, in allocation order
+	pending     map[ptr]*Vtx // created, but not written yet: some links are still unknown
+	peakPending int
+	in_deg      []int
+	out_deg     []int
+	nV, nE      int // real vertices and edges of the input graph
+
+	// Operations of the current iteration of a streaming pass (see [padStep])
+	stepA, stepW int
+	stepStart    OpCounters
+	stepRef      *OpCounters // server-visible cost of the pass's first iteration, if checking
+
+	Vtcs     []ptr        // one per input entry, sorted by U: the Real vertex, or the Out leaf of an edge
+	Vertices map[ptr]*Vtx // nil until Load
+}
 
-	Vtcs    []ptr
-	FakeRAM map[ptr](*Vtx)
+// Carried through the O-sort by U from the in-tree pass to the out-tree pass
+type incEntry struct {
+	self  ptr // the Real vertex, or the Inc leaf of an edge
+	other ptr // the root of the Real vertex's in-tree, or the Out leaf of the edge (not written yet)
 }
 
 // -------- CONSTRUCTOR FUNCTIONS --------- //
@@ -65,29 +83,34 @@ func CreateInpEdge(u, v, w int) InputEdge {
 	return InputEdge{u, v, w}
 }
 
-func CreateInputGraph(print bool, us []int, vs []int, ws []int) *InputGraph {
+func CreateInputGraph(us []int, vs []int, ws []int) *InputGraph {
 	assert(len(us) == len(vs), "mismatched input lengths")
 	assert(len(us) == len(ws), "mismatched input lengths")
 	edges := make([]InputEdge, len(us))
 	for i := 0; i < len(us); i++ {
 		edges[i] = CreateInpEdge(us[i], vs[i], ws[i])
 	}
-	return &InputGraph{print, edges}
+	return &InputGraph{edges: edges}
+}
+
+// Assertion mode: panic unless every iteration of a streaming pass costs the server exactly as
+// many accesses and allocations as the pass's first iteration
+func (inpG *InputGraph) SetCheckSteps(check bool) {
+	inpG.checkSteps = check
 }
 
-func (inpG *InputGraph) CreateOSAMGraph() *OSAMGraph {
-	return inpG.construct()
+// Builds the emulated graph in [o]; read it back with OSAMGraph.Load
+func (inpG *InputGraph) CreateOSAMGraph(o *OSAM) *OSAMGraph {
+	defer o.lock()()
+	defer o.track("IG.construct")()
+	return inpG.construct(o)
 }
 
 // -------- HELPER FUNCTIONS --------- //
 
-func (inpG *InputGraph) log(str string, newline bool) {
-	if newline {
-		fmt.Println()
-	}
-	if inpG.print {
-		fmt.Println("[IG] " + str)
-	}
+// Construction and BFS log to the logger of the graph's OSAM (see OSAM.SetLogger) under TagIG
+func (oG *OSAMGraph) logf(level LogLevel, format string, args ...interface{}) {
+	oG.osam.logger.logf(TagIG, level, format, args...)
 }
 
 // Returns the smallest power of 2 >= x
@@ -115,9 +138,7 @@ func lg(x int) int {
 	return int(math.Ceil(math.Log2(float64(x))))
 }
 
-// Dummy sort comparisons for an O-sort procedure.
-// This will just sorts normally; assume replaced by O-sort.
-// TBD fix: bad design right now with sort.Slice
+// Sort comparisons for the O-sorts (see [osort])
 func (inpG *InputGraph) compareU(i, j int) bool {
 	cmpU := inpG.edges[i].U - inpG.edges[j].U
 	if cmpU == 0 {
@@ -144,6 +165,31 @@ func (inpG *InputGraph) compareV(i, j int) bool {
 
 // TBD: for full implementation, add compareW for final O-Sort
 
+// O-sorts the edges with [less], permuting [vtcs] (if non-nil) along with them
+func (inpG *InputGraph) osort(vtcs []incEntry, less func(i, j int) bool) {
+	inpG.cmpEx += BitonicSort(edgeSorter{inpG, vtcs, less})
+}
+
+// Number of compare-exchanges done by the O-sorts of graph construction
+func (inpG *InputGraph) CompareExchanges() int {
+	return inpG.cmpEx
+}
+
+type edgeSorter struct {
+	inpG *InputGraph
+	vtcs []incEntry
+	less func(i, j int) bool
+}
+
+func (s edgeSorter) Len() int           { return len(s.inpG.edges) }
+func (s edgeSorter) Less(i, j int) bool { return s.less(i, j) }
+func (s edgeSorter) Swap(i, j int) {
+	s.inpG.edges[i], s.inpG.edges[j] = s.inpG.edges[j], s.inpG.edges[i]
+	if s.vtcs != nil {
+		s.vtcs[i], s.vtcs[j] = s.vtcs[j], s.vtcs[i]
+	}
+}
+
 func (inpG *InputGraph) computeDegs(deg *[]int) {
 	l := len(inpG.edges)
 	arr := *deg
@@ -160,80 +206,240 @@ func (inpG *InputGraph) computeDegs(deg *[]int) {
 	}
 }
 
-// Return the address [ptr] at which vertex was created
-func (oG *OSAMGraph) createVtx(id int, vtxType VtxType, other int, up ptr, lc ptr, rc ptr) int {
-	oG.pCtr++
-	v := Vtx{Id: id, Type: vtxType, Other: other, UP: up, LC: lc, RC: rc}
-	oG.FakeRAM[oG.pCtr] = &v
-	return oG.pCtr
+// Address for a vertex that is created later (so others can link to it before it exists)
+func (oG *OSAMGraph) allocVtx() ptr {
+	a := oG.osam.alloc("graph vertex")
+	oG.addrs = append(oG.addrs, a)
+	oG.stepA++
+	return a
+}
+
+// Creates the vertex at [at] in the pending buffer
+func (oG *OSAMGraph) createVtx(at ptr, id int, vtxType VtxType, other ptr, up ptr, lc ptr, rc ptr) ptr {
+	oG.pending[at] = &Vtx{Id: id, Type: vtxType, Other: other, UP: up, LC: lc, RC: rc}
+	if len(oG.pending) > oG.peakPending {
+		oG.peakPending = len(oG.pending)
+	}
+	return at
+}
+
+// Writes the pending vertex at [a] to the OSAM
+func (oG *OSAMGraph) flush(a ptr) {
+	vtx := oG.pending[a]
+	delete(oG.pending, a)
+	oG.osam.mustWrite(a, *vtx, fmt.Sprintf("vertex %v", vtx.Id))
+	oG.stepW++
+}
+
+// Upper bounds on the allocations and writes of one iteration of a streaming pass. An in-tree
+// edge allocates its leaf, the Out leaf and an internal node. The writes are the vertices that
+// become complete: the new leaf plus either two (left sibling leaving C, its parent) pairs, or,
+// at the last edge of a tree, one pair, the root and (out-trees only) the Real vertex.
+const (
+	stepAllocs = 3
+	stepWrites = 5
+)
+
+func (oG *OSAMGraph) startStep() {
+	oG.stepA, oG.stepW = 0, 0
+	oG.stepStart = oG.osam.counters()
+}
+
+// Ends an iteration with dummy allocations and writes up to [stepAllocs] / [stepWrites]
+func (oG *OSAMGraph) padStep(check bool) {
+	assert(oG.stepA <= stepAllocs && oG.stepW <= stepWrites,
+		fmt.Sprintf("iteration made %v allocations and %v writes", oG.stepA, oG.stepW))
+	for ; oG.stepA < stepAllocs; oG.stepA++ {
+		oG.osam.dummyAddr()
+	}
+	for ; oG.stepW < stepWrites; oG.stepW++ {
+		if err := oG.osam.dummyWrite("graph padding"); err != nil {
+			panic(err)
+		}
+	}
+	if !check {
+		return
+	}
+	d := oG.osam.counters().sub(oG.stepStart)
+	d = OpCounters{Accesses: d.Accesses, Allocs: d.Allocs}
+	if oG.stepRef == nil {
+		oG.stepRef = &d
+	}
+	assert(d == *oG.stepRef, fmt.Sprintf("iteration cost %+v, the first one %+v", d, *oG.stepRef))
+}
+
+// Writes out the pending tree vertices whose links are all known and that the client array [C]
+// no longer refers to (the parent of a node in C is looked up through it), in allocation order
+func (oG *OSAMGraph) flushComplete(C []ptr) {
+	var done []ptr
+	for a, vtx := range oG.pending {
+		complete := false
+		switch vtx.Type {
+		case Inc, Out:
+			complete = vtx.UP != NIL
+		case Internal, OutInternal:
+			complete = vtx.UP != NIL && vtx.RC != NIL
+		}
+		for _, c := range C {
+			complete = complete && c != a
+		}
+		if complete {
+			done = append(done, a)
+		}
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i].ctr < done[j].ctr })
+	for _, a := range done {
+		oG.flush(a)
+	}
+}
+
+// Largest number of vertices held by the client at once during construction
+func (oG *OSAMGraph) PeakPending() int {
+	return oG.peakPending
+}
+
+// Upper bound on the number of vertices of the emulated graph that depends only on V and E:
+// V Real vertices, 2E leaves and fewer than E internal nodes in the in- and in the out-trees
+func (oG *OSAMGraph) MaxVertices() int {
+	return oG.nV + 4*oG.nE
+}
+
+// Reads every vertex out of the OSAM, in allocation order, into [Vertices], padded with dummy
+// reads up to MaxVertices. OSAM addresses are read-once, so this consumes the graph: later
+// calls just return.
+func (oG *OSAMGraph) Load() error {
+	defer oG.osam.lock()()
+	if oG.Vertices != nil {
+		return nil
+	}
+	defer oG.osam.track("IG.load")()
+	vs := make(map[ptr]*Vtx, len(oG.addrs))
+	for _, a := range oG.addrs {
+		b, err := oG.osam.read(a)
+		if err != nil {
+			return err
+		}
+		vtx := blockAs[Vtx](b)
+		vs[a] = &vtx
+	}
+	for n := len(oG.addrs); n < oG.MaxVertices(); n++ {
+		if err := oG.osam.dummyRead("graph padding"); err != nil {
+			return err
+		}
+	}
+	oG.Vertices = vs
+	return nil
 }
 
 ////////////////////////////////////////
 
-func (inpG *InputGraph) construct() *OSAMGraph {
+func (inpG *InputGraph) construct(o *OSAM) *OSAMGraph {
 	l := len(inpG.edges)
 
 	osamG := OSAMGraph{
-		print: inpG.print, pCtr: 0,
-		in_deg: make([]int, l), out_deg: make([]int, l),
-		FakeRAM: make(map[ptr]*Vtx)}
-	// TBD: set the Vtcs variable
-
+		osam: o, in_deg: make([]int, l), out_deg: make([]int, l),
+		pending: make(map[ptr]*Vtx)}
 	// 1. O-SORT: inpG edges by v (head vertex)
-	sort.Slice(inpG.edges, inpG.compareV)
-	inpG.log(fmt.Sprintf("%v", inpG.edges), true)
+	inpG.osort(nil, inpG.compareV)
+	osamG.logf(LevelDebug, "%v", inpG.edges)
 	// 2. LINEAR-SCAN: Compute in_deg array
 	inpG.computeDegs(&osamG.in_deg)
-	inpG.log(fmt.Sprintf("%v", osamG.in_deg), true)
+	osamG.logf(LevelDebug, "%v", osamG.in_deg)
+	for _, d := range osamG.in_deg {
+		if d == NONE {
+			osamG.nE++
+		}
+	}
+	osamG.nV = l - osamG.nE
 	// 3. LINEAR-SCAN + binary-pointer-tree: create inc_vtcs array
-	osamG.Vtcs = inpG.createIncTrees(&osamG)
-
-	// // 4. O-SORT: edges by u, inc_vtcs by u
-	// sort.Slice(inpG.edges, inpG.compareV)
-	// sort.Slice() // TBD: will need to fix sorting
-
-	// // 5. LINEAR-SCAN: Compute out_deg array
-	// inpG.computeDegs(&osamG.out_deg)
-	// inpG.log(fmt.Sprintf("Out-degrees: %v", osamG.out_deg), true)
-
+	inc_vtcs := inpG.createIncTrees(&osamG)
+
+	// 4. O-SORT: edges by u, inc_vtcs by u
+	inpG.osort(inc_vtcs, inpG.compareU)
+	osamG.logf(LevelDebug, "%v", inpG.edges)
+	// 5. LINEAR-SCAN: Compute out_deg array
+	inpG.computeDegs(&osamG.out_deg)
+	osamG.logf(LevelDebug, "Out-degrees: %v", osamG.out_deg)
 	// 6. LINEAR-SCAN + binary-pointer-tree: compute out_vtcs array
+	out_vtcs := inpG.createOutTrees(&osamG, inc_vtcs)
+	assert(len(osamG.pending) == 0, "vertices left unwritten after construction")
 
+	osamG.Vtcs = make([]ptr, l)
+	for i, e := range out_vtcs {
+		osamG.Vtcs[i] = e.self
+	}
 	return &osamG
 }
 
 // Binary-pointer-tree construction in a streaming pass over the leaves.
 // Requires O(log E) client storage, indicated by the client array C (and O(1)-size variables)
 
-func (inpG *InputGraph) createIncTrees(osamG *OSAMGraph) []ptr {
-	l := len(inpG.edges)
-	assert(l == len(osamG.in_deg), "mismatched lengths")
-	assert(osamG.in_deg[0] != NONE, "in_deg not created properly")
-	store := osamG.FakeRAM
+func (inpG *InputGraph) createIncTrees(osamG *OSAMGraph) []incEntry {
+	return inpG.createTrees(osamG, osamG.in_deg, nil)
+}
+
+// Same pass over the edges sorted by U, writing the Real vertices of [inc_vtcs] (permuted along
+// with the edges) and the Out leaves at the addresses the in-tree pass linked the Inc leaves to
+func (inpG *InputGraph) createOutTrees(osamG *OSAMGraph, inc_vtcs []incEntry) []incEntry {
+	return inpG.createTrees(osamG, osamG.out_deg, inc_vtcs)
+}
 
-	inc_vtcs := make([]ptr, l)
+// Builds the in-trees if [inc_vtcs] is nil, the out-trees otherwise. The root of an in-tree is
+// allocated ahead, together with its Real vertex, since only the Real vertex's entry is carried
+// on to the out-tree pass, which writes it.
+func (inpG *InputGraph) createTrees(osamG *OSAMGraph, deg []int, inc_vtcs []incEntry) []incEntry {
+	l := len(inpG.edges)
+	assert(l == len(deg), "mismatched lengths")
+	assert(deg[0] != NONE, "degrees not created properly")
+	store := osamG.pending
+
+	out := inc_vtcs != nil
+	leafType, internalType := Inc, Internal
+	if out {
+		leafType, internalType = Out, OutInternal
+	}
+	vtcs := make([]incEntry, l)
 
 	// Client state
 	m := NONE
 	z := NONE
-	v := NONE
+	v := NIL
+	root := NIL // in-trees only
 	startInd := NONE
-	C := make([]int, lg(l)+1)
+	C := make([]ptr, lg(l)+1)
 	for k := 0; k < len(C); k++ {
-		C[k] = NONE
+		C[k] = NIL
 	}
 
 	// Streaming pass
+	osamG.stepRef = nil
 	for i := 0; i < l; i++ {
+		osamG.startStep()
 		// fmt.Printf("Edge list ind: %v \n", i)
 		id := inpG.edges[i].V
-		if osamG.in_deg[i] != NONE { // Vertex encountered: start new tree creation
+		if out {
+			id = inpG.edges[i].U
+		}
+		if deg[i] != NONE { // Vertex encountered: start new tree creation
 			// fmt.Printf("Starting: state of C: %v \n", C)
-			m = osamG.in_deg[i]
+			m = deg[i]
 			z = 2*m - nextPowTwo(m)
 			startInd = i
-			v = osamG.createVtx(id, Real, NONE, NONE, NONE, NONE)
+			if out {
+				// pending until its out-tree is done
+				v = osamG.createVtx(inc_vtcs[i].self, id, Real, NIL, NIL, inc_vtcs[i].other, NIL)
+				if m == 0 {
+					osamG.flush(v)
+				}
+			} else {
+				v = osamG.allocVtx()
+				root = NIL
+				if m > 0 {
+					root = osamG.allocVtx()
+				}
+			}
 			// fmt.Printf("Created vtx @ addr %v \n", v)
-			inc_vtcs[i] = v
+			vtcs[i] = incEntry{v, root}
 		} else { // Else: Edge encountered: continue in current tree run
 			ii := i - startInd
 			lvl := 0
@@ -241,46 +447,153 @@ func (inpG *InputGraph) createIncTrees(osamG *OSAMGraph) []ptr {
 				lvl = 1
 			}
 			// A. Create the actual leaf node
-			me := osamG.createVtx(id, Inc, inpG.edges[i].U, NONE, NONE, NONE)
+			var me, other ptr
+			if out {
+				me, other = inc_vtcs[i].other, inc_vtcs[i].self
+			} else {
+				if m == 1 {
+					me = root
+				} else {
+					me = osamG.allocVtx()
+				}
+				other = osamG.allocVtx()
+			}
+			osamG.createVtx(me, id, leafType, other, NIL, NIL, NIL)
 			// fmt.Printf("Created vtx @ addr %v \n", me)
 			// fmt.Printf("My level: %v \n", lvl)
-			inc_vtcs[i] = me
-			if C[lvl] == NONE {
+			vtcs[i] = incEntry{me, other}
+			if C[lvl] == NIL {
 				C[lvl] = me
 				// fmt.Printf("%v + here + lvl %v + %v \n", i, lvl, C[lvl])
 			} else {
 				parent := store[C[lvl]].UP
 				store[me].UP = parent
 				store[parent].RC = me
-				C[lvl] = NONE
+				C[lvl] = NIL
 				// fmt.Printf("%v + here2 + %v \n", i, C[lvl])
 			}
 			// B. Create the corresponding internal node
 			if ii == m {
 				maxLvl := lg(m)
+				assert(out || C[maxLvl] == root, "in-tree root not at its preallocated address")
 				store[C[maxLvl]].UP = v
-				store[v].LC = C[maxLvl]
-				C[maxLvl] = NONE
+				if out {
+					store[v].RC = C[maxLvl]
+					osamG.flush(v)
+				}
+				C[maxLvl] = NIL
 			} else {
 				lvl = 1 + maxDivPowTwo(ii)
 				if ii > z {
 					lvl = 1 + maxDivPowTwo(2*ii-z)
 				}
-				intNode := osamG.createVtx(id, Internal, NONE, NONE, C[lvl-1], NONE)
+				at := root
+				if out || lvl != lg(m) {
+					at = osamG.allocVtx()
+				}
+				intNode := osamG.createVtx(at, id, internalType, NIL, NIL, C[lvl-1], NIL)
 				// fmt.Printf("Created vtx @ addr %v \n", intNode)
 				// fmt.Printf("New level: %v \n", lvl)
 				// fmt.Printf("LC node: %v \n", C[lvl-1])
 				store[C[lvl-1]].UP = intNode
-				if C[lvl] == NONE {
+				if C[lvl] == NIL {
 					C[lvl] = intNode
 				} else {
 					parent := store[C[lvl]].UP
 					store[intNode].UP = parent
 					store[parent].RC = intNode
-					C[lvl] = NONE
+					C[lvl] = NIL
+				}
+			}
+		}
+		osamG.flushComplete(C)
+		osamG.padStep(inpG.checkSteps)
+	}
+	return vtcs
+}
+
+// Checks the emulated graph (loading it first, see Load): every Real vertex has at most its two
+// tree roots as neighbours, every Inc/Out leaf exactly its parent and the other leaf of its edge,
+// and every internal node exactly three. Links must go both ways, and each tree must have one
+// leaf per edge of its vertex.
+func (oG *OSAMGraph) VerifyDegrees() error {
+	if err := oG.Load(); err != nil {
+		return err
+	}
+	bad := func(p ptr, format string, args ...interface{}) error {
+		return fmt.Errorf("%w: vertex %v @ %v: %v", ErrMalformedGraph, oG.Vertices[p], p, fmt.Sprintf(format, args...))
+	}
+	inLeaves, outLeaves := 0, 0
+	for p, vtx := range oG.Vertices {
+		deg := 0
+		for _, q := range []ptr{vtx.UP, vtx.LC, vtx.RC} {
+			if q != NIL {
+				deg++
+				if oG.Vertices[q] == nil {
+					return bad(p, "dangling link to %v", q)
 				}
 			}
 		}
+		if vtx.LC != NIL && oG.Vertices[vtx.LC].UP != p || vtx.RC != NIL && oG.Vertices[vtx.RC].UP != p {
+			return bad(p, "child does not link back")
+		}
+		switch vtx.Type {
+		case Real:
+			if vtx.UP != NIL || deg > 2 {
+				return bad(p, "real vertex with degree %v", deg)
+			}
+		case Inc, Out:
+			other := oG.Vertices[vtx.Other]
+			if other == nil || other.Other != p || other.Type == vtx.Type {
+				return bad(p, "leaf not linked to the other side of its edge")
+			}
+			if vtx.UP == NIL || deg != 1 {
+				return bad(p, "leaf with degree %v", deg+1)
+			}
+			if vtx.Type == Inc {
+				inLeaves++
+			} else {
+				outLeaves++
+			}
+		case Internal, OutInternal:
+			if deg != 3 {
+				return bad(p, "internal vertex with degree %v", deg)
+			}
+		}
+		if vtx.UP != NIL {
+			up := oG.Vertices[vtx.UP]
+			if up.LC != p && up.RC != p {
+				return bad(p, "parent does not link back")
+			}
+		}
+	}
+	if inLeaves != outLeaves {
+		return fmt.Errorf("%w: %v Inc leaves but %v Out leaves", ErrMalformedGraph, inLeaves, outLeaves)
+	}
+	// [Vtcs] is aligned with [out_deg]; in-trees are checked by their total leaf count
+	inTotal := 0
+	for i, p := range oG.Vtcs {
+		if oG.Vertices[p].Type != Real {
+			continue
+		}
+		inTotal += oG.countLeaves(oG.Vertices[p].LC)
+		if n := oG.countLeaves(oG.Vertices[p].RC); n != oG.out_deg[i] {
+			return bad(p, "out-tree has %v leaves, out-degree is %v", n, oG.out_deg[i])
+		}
+	}
+	if inTotal != inLeaves {
+		return fmt.Errorf("%w: in-trees hold %v of %v Inc leaves", ErrMalformedGraph, inTotal, inLeaves)
+	}
+	return nil
+}
+
+func (oG *OSAMGraph) countLeaves(p ptr) int {
+	if p == NIL {
+		return 0
+	}
+	vtx := oG.Vertices[p]
+	if vtx.Type == Inc || vtx.Type == Out {
+		return 1
 	}
-	return inc_vtcs
+	return oG.countLeaves(vtx.LC) + oG.countLeaves(vtx.RC)
 }
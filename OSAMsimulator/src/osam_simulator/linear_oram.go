@@ -0,0 +1,85 @@
+package osam_simulator
+
+import "math/rand"
+
+// ------------- Linear-scan ORAM ------------- //
+// Trivial baseline: every access reads and rewrites the whole memory.
+// Leaves only exist so OSAM can keep drawing addresses; they are ignored for placement.
+// NOTE: a real implementation pads the memory to a fixed capacity; we count the blocks
+// currently stored, which is enough to compare costs against the tree-based schemes.
+
+type LinearORAM struct {
+	nl     int
+	logger *Logger
+	stats  ORAMStats
+	blocks []slot
+	rec    *Transcript
+}
+
+func CreateLinearORAM(nleaves int) *LinearORAM {
+	return &LinearORAM{nl: nleaves}
+}
+
+func (oram *LinearORAM) logf(format string, args ...interface{}) {
+	oram.logger.logf(TagORAM, LevelDebug, format, args...)
+}
+
+func (oram *LinearORAM) numLeaves() int {
+	return oram.nl
+}
+
+// Linear scans are deterministic
+func (oram *LinearORAM) setRand(rng *rand.Rand) {}
+
+func (oram *LinearORAM) setLogger(l *Logger) {
+	oram.logger = l
+}
+
+func (oram *LinearORAM) setRecorder(rec *Transcript) {
+	oram.rec = rec
+}
+
+func (oram *LinearORAM) Stats() ORAMStats {
+	return oram.stats
+}
+
+func (oram *LinearORAM) readRmAccess(a addr, callerMsg string) (Block, error) {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return Block{}, leafErr(a.leaf, oram.nl)
+	}
+	oram.logf("ReadAndRm SCAN: %v, called from: %v", a, callerMsg)
+	oram.rec.record(0, OpRead, NONE, nil)
+	oram.stats.Accesses++
+	oram.stats.BlocksRead += len(oram.blocks)
+	for j, s := range oram.blocks {
+		if s.a == a {
+			oram.blocks = append(oram.blocks[:j], oram.blocks[j+1:]...)
+			return s.b, nil
+		}
+	}
+	oram.logf("Read yielded None when reading %v", a)
+	return Block{Data: NONE, IsNone: true}, nil
+}
+
+// Write back the whole (re-encrypted) memory
+func (oram *LinearORAM) evict() error {
+	oram.rec.record(0, OpWrite, NONE, nil)
+	oram.stats.BlocksWritten += len(oram.blocks)
+	return nil
+}
+
+func (oram *LinearORAM) evictWrite(a addr, value interface{}) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	oram.logf("Evict=Write: storing value %v at %v", value, a)
+	oram.blocks = append(oram.blocks, slot{a, Block{value, false}})
+	return oram.evict()
+}
+
+func (oram *LinearORAM) evictDummy(a addr) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	return oram.evict()
+}
@@ -0,0 +1,92 @@
+package osam_simulator
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ------------- Logging ------------- //
+// Every OSAM owns a Logger (set with OSAM.SetLogger) that is shared with its ORAM and with the
+// SmartPointers / BSPs and emulated graphs built on it. Lines look like "INFO [BSP] GET: 3_17" and only go out if
+// both the level and the component tag are enabled. A nil *Logger discards everything, which is
+// the default, so independent simulations never share any logging state.
+
+type LogLevel int
+
+const (
+	// Every ORAM access, allocation and node visited
+	LevelDebug LogLevel = iota
+	// Pointer API calls and object lifetimes
+	LevelInfo
+	// Suspicious but recoverable situations, e.g. stash overflows
+	LevelWarn
+	LevelError
+	// Disables all output
+	LevelOff
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "OFF"
+}
+
+// Component tags
+const (
+	TagORAM = "ORAM"
+	TagOSAM = "OSAM"
+	TagSP   = "SP"
+	TagBSP  = "BSP"
+	TagIG   = "IG"
+)
+
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level LogLevel
+	tags  map[string]bool // nil = all components
+}
+
+// Logs messages of at least [level] to [w]; if [tags] are given, only from those components
+func NewLogger(w io.Writer, level LogLevel, tags ...string) *Logger {
+	l := &Logger{w: w, level: level}
+	if len(tags) > 0 {
+		l.tags = make(map[string]bool, len(tags))
+		for _, t := range tags {
+			l.tags[t] = true
+		}
+	}
+	return l
+}
+
+// Whether a message of [level] from [tag] would be written; lets callers skip building it
+func (l *Logger) Enabled(tag string, level LogLevel) bool {
+	if l == nil || level < l.level || level >= LevelOff {
+		return false
+	}
+	return l.tags == nil || l.tags[tag]
+}
+
+func (l *Logger) log(tag string, level LogLevel, msg string) {
+	if !l.Enabled(tag, level) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%v [%v] %v\n", level, tag, msg)
+}
+
+func (l *Logger) logf(tag string, level LogLevel, format string, args ...interface{}) {
+	if l.Enabled(tag, level) {
+		l.log(tag, level, fmt.Sprintf(format, args...))
+	}
+}
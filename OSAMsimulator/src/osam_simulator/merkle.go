@@ -0,0 +1,119 @@
+package osam_simulator
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// ------------- Merkle tree over the buckets ------------- //
+// Optional integrity layer for the tree-based ORAMs (malicious-server model).
+// The server stores h(n) = H(bucket n || h(2n) || h(2n+1)) next to every bucket; the client only keeps
+// the root hash. Opening a path recomputes its hashes from the leaf up, using the server's hashes for
+// the siblings, and compares the result with the client's root. Closing a path recomputes the hashes
+// of the rewritten buckets and updates the root.
+// The hashed bucket is its ciphertext when encryption is on, else its serialization.
+
+type merkleTree struct {
+	hashes [][]byte // server side, one per bucket
+	root   []byte   // client side
+	count  int      // hash computations so far
+}
+
+func (t *bucketTree) enableIntegrity() {
+	t.merkle = &merkleTree{hashes: make([][]byte, len(t.buckets))}
+	t.rehashAll()
+}
+
+// Recomputes every hash bottom-up, e.g. after the server-side representation changed
+func (t *bucketTree) rehashAll() {
+	for n := len(t.buckets) - 1; n >= 1; n-- {
+		t.merkle.hashes[n] = t.hashNode(n, t.childHash(2*n), t.childHash(2*n+1))
+	}
+	t.merkle.root = t.merkle.hashes[1]
+}
+
+// What the server currently stores for bucket [n]
+func (t *bucketTree) serverBucket(n int) []byte {
+	if t.sealer != nil {
+		return t.sealed[n]
+	}
+	return encodeBucket(t.buckets[n], t.z)
+}
+
+func (t *bucketTree) childHash(c int) []byte {
+	if c >= len(t.buckets) {
+		return nil
+	}
+	return t.merkle.hashes[c]
+}
+
+func (t *bucketTree) hashNode(n int, left, right []byte) []byte {
+	t.merkle.count++
+	h := sha256.New()
+	h.Write(t.serverBucket(n))
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Checks the buckets from [n] up to the root against the client's root hash.
+// Blames the deepest bucket whose content disagrees with the hash the server stores for it.
+func (t *bucketTree) verifyNode(n int) error {
+	if t.merkle == nil {
+		return nil
+	}
+	bad := NONE
+	var h []byte
+	for child := NONE; n >= 1; child, n = n, n/2 {
+		left, right := t.childHash(2*n), t.childHash(2*n+1)
+		if child == 2*n {
+			left = h
+		} else if child == 2*n+1 {
+			right = h
+		}
+		h = t.hashNode(n, left, right)
+		if bad == NONE && !bytes.Equal(h, t.merkle.hashes[n]) {
+			bad = n
+		}
+	}
+	if !bytes.Equal(h, t.merkle.root) {
+		if bad == NONE {
+			bad = 1 // server rewrote the hashes consistently: only the root can be blamed
+		}
+		return &IntegrityError{bad, "Merkle hash mismatch"}
+	}
+	return nil
+}
+
+// Rehashes the buckets from [n] up to the root after they were rewritten
+func (t *bucketTree) updateNode(n int) {
+	if t.merkle == nil {
+		return
+	}
+	for ; n >= 1; n /= 2 {
+		t.merkle.hashes[n] = t.hashNode(n, t.childHash(2*n), t.childHash(2*n+1))
+	}
+	t.merkle.root = t.merkle.hashes[1]
+}
+
+// SHA-256 over everything the server stores, bucket by bucket (no Merkle tree needed)
+func (t *bucketTree) digest() []byte {
+	t.sealAll()
+	h := sha256.New()
+	for n := 1; n < len(t.buckets); n++ {
+		h.Write(t.serverBucket(n))
+	}
+	return h.Sum(nil)
+}
+
+// Simulates a malicious server modifying bucket [n]
+func (t *bucketTree) corrupt(n int) {
+	t.sealAll()
+	if t.sealer != nil {
+		ct := append([]byte(nil), t.sealed[n]...)
+		ct[len(ct)-1] ^= 1
+		t.sealed[n] = ct
+		return
+	}
+	t.buckets[n] = append(t.buckets[n], slot{addr{NONE - 1, 0}, Block{Data: "forged", IsNone: false}})
+}
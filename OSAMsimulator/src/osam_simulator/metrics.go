@@ -0,0 +1,114 @@
+package osam_simulator
+
+import "sort"
+
+// ------------- Per-operation cost accounting ------------- //
+// Every SmartPointer / BSP API call and internal helper is wrapped in a tracked scope, named
+// e.g. "BSP.Get" or "BSP.descend". Scopes are inclusive: the cost of a helper also counts
+// towards the API call that invoked it. [Total] covers everything since the OSAM was created
+// (or since ResetMetrics), including work done outside any scope.
+
+type OpCounters struct {
+	Calls       int
+	Accesses    int // physical ORAM accesses
+	BlocksMoved int // blocks read + written, including dummies
+	BytesMoved  int // ciphertext bytes read + written (0 unless encryption is enabled)
+	Hashes      int // bucket hash computations (0 unless integrity checks are enabled)
+	Allocs      int
+	Enqueues    int
+	Dequeues    int
+}
+
+func (c *OpCounters) add(d OpCounters) {
+	c.Calls += d.Calls
+	c.Accesses += d.Accesses
+	c.BlocksMoved += d.BlocksMoved
+	c.BytesMoved += d.BytesMoved
+	c.Hashes += d.Hashes
+	c.Allocs += d.Allocs
+	c.Enqueues += d.Enqueues
+	c.Dequeues += d.Dequeues
+}
+
+func (c OpCounters) sub(d OpCounters) OpCounters {
+	return OpCounters{c.Calls - d.Calls, c.Accesses - d.Accesses, c.BlocksMoved - d.BlocksMoved,
+		c.BytesMoved - d.BytesMoved, c.Hashes - d.Hashes, c.Allocs - d.Allocs, c.Enqueues - d.Enqueues,
+		c.Dequeues - d.Dequeues}
+}
+
+type MetricsReport struct {
+	Total OpCounters
+	Ops   map[string]OpCounters
+}
+
+// Names of the tracked operations, sorted
+func (r MetricsReport) Names() []string {
+	names := make([]string, 0, len(r.Ops))
+	for k := range r.Ops {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Average cost of one call of [name]
+func (r MetricsReport) PerCall(name string) OpCounters {
+	c := r.Ops[name]
+	if c.Calls == 0 {
+		return OpCounters{}
+	}
+	return OpCounters{1, c.Accesses / c.Calls, c.BlocksMoved / c.Calls, c.BytesMoved / c.Calls,
+		c.Hashes / c.Calls, c.Allocs / c.Calls, c.Enqueues / c.Calls, c.Dequeues / c.Calls}
+}
+
+type metrics struct {
+	allocs   int
+	enqueues int
+	dequeues int
+	base     OpCounters // totals at the last reset
+	ops      map[string]*OpCounters
+}
+
+func createMetrics() *metrics {
+	return &metrics{ops: make(map[string]*OpCounters)}
+}
+
+// Running totals since the OSAM was created
+func (osam *OSAM) counters() OpCounters {
+	s := osam.oram.Stats()
+	m := osam.metrics
+	return OpCounters{Accesses: s.Accesses, BlocksMoved: s.BlocksRead + s.BlocksWritten,
+		BytesMoved: s.BytesRead + s.BytesWritten, Hashes: s.Hashes, Allocs: m.allocs, Enqueues: m.enqueues, Dequeues: m.dequeues}
+}
+
+// Starts a tracked scope; call the returned function to end it:
+//
+//	defer osam.track("BSP.Get")()
+func (osam *OSAM) track(name string) func() {
+	start := osam.counters()
+	return func() {
+		d := osam.counters().sub(start)
+		d.Calls = 1
+		c, ok := osam.metrics.ops[name]
+		if !ok {
+			c = &OpCounters{}
+			osam.metrics.ops[name] = c
+		}
+		c.add(d)
+	}
+}
+
+func (osam *OSAM) Metrics() MetricsReport {
+	defer osam.lock()()
+	r := MetricsReport{Total: osam.counters().sub(osam.metrics.base), Ops: make(map[string]OpCounters)}
+	for k, v := range osam.metrics.ops {
+		r.Ops[k] = *v
+	}
+	return r
+}
+
+func (osam *OSAM) ResetMetrics() {
+	defer osam.lock()()
+	osam.metrics.base = osam.counters()
+	osam.metrics.ops = make(map[string]*OpCounters)
+}
@@ -0,0 +1,248 @@
+package osam_simulator
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// ------------- Statistical obliviousness tester ------------- //
+// Runs two programs with the same operation shape (but different secret data / pointer structure)
+// many times under different seeds, records the server transcripts, and tests whether they can be
+// told apart:
+//  - chi-square homogeneity test on the distribution of events, keyed by (tree, op, leaf) for
+//    path accesses and (tree, op, bucket) for single buckets, pooled over all runs. A write of
+//    exactly what the last read of its tree fetched is one key, "rewrite": counting its leaf again
+//    would count every path twice, and the doubled counts would inflate the statistic.
+//  - chi-square homogeneity test on the gap between a path read and the last write of the same
+//    path (in events, log2-binned): links a write to the read that follows it
+//  - two-sample Kolmogorov-Smirnov test on the number of events per run
+// A test failing at significance [Alpha] flags the pair as leaky. A chi-square test with fewer than
+// two distinct values (e.g. LinearORAM, which scans everything) cannot tell anything apart: it is
+// listed in NotApplicable, with a NaN p-value, instead of passing.
+
+// A program under test; it must only interact with the server through [o]
+type Program func(o *OSAM)
+
+type ObliviousnessConfig struct {
+	Runs    int
+	Alpha   float64
+	Seed    int64       // run i of A uses Seed+i, run i of B uses Seed+Runs+i
+	NewORAM func() ORAM // fresh backend for every run
+}
+
+type ObliviousnessReport struct {
+	Runs int
+
+	EventChiSquare float64
+	EventDF        int
+	EventPValue    float64
+
+	GapChiSquare float64
+	GapDF        int
+	GapPValue    float64
+
+	AccessKS     float64 // KS statistic D on events per run
+	AccessPValue float64
+
+	Leaky         bool
+	Reasons       []string
+	NotApplicable []string // tests that could not be run on these transcripts
+}
+
+// Histogram key of one transcript event (one per bucket for events that are not a path)
+type eventKey struct {
+	tree, leaf, bucket int
+	op                 string
+	rewrite            bool // write of the path / buckets of the last read
+}
+
+type transcriptStats struct {
+	events map[eventKey]int
+	gaps   map[int]int // log2-binned read-after-write gaps (0 = path never written before)
+	counts []float64   // events per run
+}
+
+func CompareTranscripts(progA, progB Program, cfg ObliviousnessConfig) ObliviousnessReport {
+	assert(cfg.Runs > 0, "need at least one run")
+	a := runTranscripts(progA, cfg, cfg.Seed)
+	b := runTranscripts(progB, cfg, cfg.Seed+int64(cfg.Runs))
+
+	r := ObliviousnessReport{Runs: cfg.Runs}
+	var ok bool
+	r.EventChiSquare, r.EventDF, r.EventPValue, ok = chiSquareHomogeneity(a.events, b.events)
+	if !ok {
+		r.NotApplicable = append(r.NotApplicable, "event distribution (fewer than two distinct events)")
+	} else if r.EventPValue < cfg.Alpha {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("event distributions differ (chi2=%.2f, df=%v, p=%.3g)",
+			r.EventChiSquare, r.EventDF, r.EventPValue))
+	}
+	r.GapChiSquare, r.GapDF, r.GapPValue, ok = chiSquareHomogeneity(a.gaps, b.gaps)
+	if !ok {
+		r.NotApplicable = append(r.NotApplicable, "read-after-write gaps (fewer than two distinct gaps)")
+	} else if r.GapPValue < cfg.Alpha {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("read-after-write gaps differ (chi2=%.2f, df=%v, p=%.3g)",
+			r.GapChiSquare, r.GapDF, r.GapPValue))
+	}
+	r.AccessKS, r.AccessPValue = ksTwoSample(a.counts, b.counts)
+	if r.AccessPValue < cfg.Alpha {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("access counts differ (D=%.3f, p=%.3g)", r.AccessKS, r.AccessPValue))
+	}
+	r.Leaky = len(r.Reasons) > 0
+	return r
+}
+
+func runTranscripts(prog Program, cfg ObliviousnessConfig, seed int64) transcriptStats {
+	st := transcriptStats{events: make(map[eventKey]int), gaps: make(map[int]int), counts: make([]float64, cfg.Runs)}
+	for i := 0; i < cfg.Runs; i++ {
+		o := CreateOSAM(cfg.NewORAM(), SeededRand(seed+int64(i)))
+		rec := NewTranscript()
+		o.SetRecorder(rec)
+		prog(o)
+		lastWrite := make(map[[2]int]int) // (tree, leaf) -> seq
+		lastRead := make(map[int]TranscriptEvent)
+		for _, e := range rec.Events {
+			st.counts[i]++
+			if e.Op == OpRead {
+				lastRead[e.Tree] = e
+			}
+			if r, ok := lastRead[e.Tree]; ok && e.Op == OpWrite && r.Leaf == e.Leaf &&
+				fmt.Sprint(r.Buckets) == fmt.Sprint(e.Buckets) {
+				st.events[eventKey{tree: e.Tree, leaf: NONE, bucket: NONE, op: e.Op, rewrite: true}]++
+			} else if e.Leaf != NONE || len(e.Buckets) == 0 {
+				st.events[eventKey{tree: e.Tree, leaf: e.Leaf, bucket: NONE, op: e.Op}]++
+			} else {
+				for _, n := range e.Buckets {
+					st.events[eventKey{tree: e.Tree, leaf: NONE, bucket: n, op: e.Op}]++
+				}
+			}
+			if e.Leaf == NONE {
+				continue
+			}
+			p := [2]int{e.Tree, e.Leaf}
+			if e.Op == OpWrite {
+				lastWrite[p] = e.Seq
+			} else if w, ok := lastWrite[p]; ok {
+				st.gaps[bits.Len(uint(e.Seq-w))]++
+			} else {
+				st.gaps[0]++
+			}
+		}
+	}
+	return st
+}
+
+// ------------ Statistics ------------ //
+
+// Chi-square test that two histograms come from the same distribution: (statistic, df, p-value,
+// applicable). Not applicable (p-value NaN) with fewer than two distinct keys or an empty histogram.
+func chiSquareHomogeneity[K comparable](a, b map[K]int) (float64, int, float64, bool) {
+	totalA, totalB := 0, 0
+	keys := make(map[K]bool)
+	for k, v := range a {
+		totalA += v
+		keys[k] = true
+	}
+	for k, v := range b {
+		totalB += v
+		keys[k] = true
+	}
+	df := len(keys) - 1
+	if df < 1 || totalA == 0 || totalB == 0 {
+		return 0, 0, math.NaN(), false
+	}
+	n := float64(totalA + totalB)
+	stat := 0.0
+	for k := range keys {
+		col := float64(a[k] + b[k])
+		eA := float64(totalA) * col / n
+		eB := float64(totalB) * col / n
+		stat += (float64(a[k])-eA)*(float64(a[k])-eA)/eA + (float64(b[k])-eB)*(float64(b[k])-eB)/eB
+	}
+	return stat, df, gammaQ(float64(df)/2, stat/2), true
+}
+
+// Two-sample Kolmogorov-Smirnov test: (D, asymptotic p-value)
+func ksTwoSample(a, b []float64) (float64, float64) {
+	a = append([]float64(nil), a...)
+	b = append([]float64(nil), b...)
+	sort.Float64s(a)
+	sort.Float64s(b)
+	d := 0.0
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		x := math.Min(a[i], b[j])
+		for i < len(a) && a[i] == x {
+			i++
+		}
+		for j < len(b) && b[j] == x {
+			j++
+		}
+		d = math.Max(d, math.Abs(float64(i)/float64(len(a))-float64(j)/float64(len(b))))
+	}
+	if d == 0 {
+		return 0, 1
+	}
+	ne := float64(len(a)*len(b)) / float64(len(a)+len(b))
+	lambda := (math.Sqrt(ne) + 0.12 + 0.11/math.Sqrt(ne)) * d
+	return d, ksQ(lambda)
+}
+
+// Kolmogorov distribution tail: Q(lambda) = 2 sum_{j>=1} (-1)^(j-1) exp(-2 j^2 lambda^2)
+func ksQ(lambda float64) float64 {
+	sum, sign := 0.0, 1.0
+	for j := 1; j <= 100; j++ {
+		term := sign * 2 * math.Exp(-2*float64(j*j)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-10 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, sum))
+}
+
+// Regularized upper incomplete gamma function Q(s, x), i.e. the chi-square tail for s = df/2, x = stat/2
+func gammaQ(s, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(s)
+	if x < s+1 { // series for P(s, x)
+		sum, term := 1/s, 1/s
+		for n := 1; n < 1000; n++ {
+			term *= x / (s + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-14 {
+				break
+			}
+		}
+		return 1 - sum*math.Exp(-x+s*math.Log(x)-lg)
+	}
+	// continued fraction for Q(s, x) (modified Lentz)
+	const tiny = 1e-300
+	b := x + 1 - s
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - s)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-14 {
+			break
+		}
+	}
+	return math.Exp(-x+s*math.Log(x)-lg) * h
+}
@@ -2,62 +2,318 @@ package osam_simulator
 
 import (
 	"fmt"
-	"log"
+	"math/rand"
 )
 
-// ------------- Dummy PathORAM ------------- //
+// ------------- ORAM backend interface ------------- //
+// Everything OSAM needs from the underlying ORAM scheme.
+// An access is [readRmAccess] followed by exactly one [evict] or [evictWrite].
+// Errors are ErrLeafOutOfRange (or ErrOutOfCapacity) for a bad address, *IntegrityError
+// when a bucket read from the server fails verification, and ErrStashOverflow (see stash.go).
+// ErrStashOverflow is only checked once the access is over: by then [evictWrite] has stored its
+// value, and the block taken out by [readRmAccess] stays out.
+type ORAM interface {
+	// Returns the block stored at [a] (or a None block) and removes it from the ORAM
+	readRmAccess(a addr, callerMsg string) (Block, error)
+	// Finishes the access started by the preceding [readRmAccess]
+	evict() error
+	// Finishes the preceding access, additionally storing [value] at [a]
+	evictWrite(a addr, value interface{}) error
+	// Finishes the preceding access exactly as [evictWrite] would for [a], storing nothing
+	evictDummy(a addr) error
+	// Number of leaves that addresses can be mapped to
+	numLeaves() int
+	// Cumulative cost counters
+	Stats() ORAMStats
+	// Source for all randomness drawn by the backend (see randomness.go)
+	setRand(rng *rand.Rand)
+	// Where to log server-visible accesses (nil = don't record; see transcript.go)
+	setRecorder(rec *Transcript)
+	// Debug log of the backend, shared with the OSAM (see logger.go)
+	setLogger(l *Logger)
+}
+
+// Server-side cost of the accesses made so far. Block counts include dummy blocks.
+type ORAMStats struct {
+	Accesses      int
+	BlocksRead    int
+	BlocksWritten int
+	StashMax      int // 0 for schemes without a stash
+
+	// Only with encryption enabled (see encryption.go), else 0
+	Encryptions  int // buckets (re-)encrypted
+	BytesRead    int // ciphertext bytes fetched
+	BytesWritten int // ciphertext bytes sent back
+	ServerBytes  int // ciphertext bytes currently stored by the server
+
+	// Only with integrity checks enabled (see merkle.go), else 0
+	Hashes int // bucket hash computations
+}
+
+// ------------- PathORAM ------------- //
 // see common.go for other type defs
 
+// Backend used by a PathORAM, chosen at construction time
+type ORAMMode int
+
+const (
+	// Fast "ideal" simulation: one map per leaf, no tree/buckets/stash
+	IdealORAM ORAMMode = iota
+	// Real Path ORAM: binary tree of Z-sized buckets plus a client stash
+	TreeORAM
+)
+
+// Bucket capacity Z used by TreeORAM
+const BucketSize = 4
+
 type PathORAM struct {
-	nl    int
-	arr   [](map[int]Block)
-	print bool
+	nl     int
+	mode   ORAMMode
+	logger *Logger
+	stats  ORAMStats
+
+	// IdealORAM state
+	arr [](map[int]Block)
+	rec *Transcript
+
+	// TreeORAM state
+	tree     *bucketTree
+	stash    []slot
+	stashLog *stashTracker
+	openLeaf int // path read by the last [readRmAccess] and not yet evicted (NONE if closed), both modes
 }
 
-func CreateORAM(nleaves int, print bool) *PathORAM {
+// Panics with ErrUnknownMode for a [mode] other than IdealORAM / TreeORAM
+func CreateORAM(nleaves int, mode ORAMMode) *PathORAM {
 	me := &PathORAM{}
 	me.nl = nleaves
-	me.print = print
-	me.arr = make([](map[int]Block), nleaves)
-	for i := 0; i < nleaves; i++ {
-		me.arr[i] = make(map[int]Block)
+	me.mode = mode
+	me.openLeaf = NONE
+	switch mode {
+	case IdealORAM:
+		me.arr = make([](map[int]Block), nleaves)
+		for i := 0; i < nleaves; i++ {
+			me.arr[i] = make(map[int]Block)
+		}
+	case TreeORAM:
+		me.tree = createBucketTree(nleaves, BucketSize)
+		me.stashLog = createStashTracker()
+	default:
+		panic(fmt.Errorf("%w: %v", ErrUnknownMode, mode))
 	}
 	return me
 }
 
-func (oram *PathORAM) log(str string) {
-	if oram.print && !suppressPrint {
-		fmt.Println("[ORAM] " + str)
+func (oram *PathORAM) numLeaves() int {
+	return oram.nl
+}
+
+func (oram *PathORAM) setRand(rng *rand.Rand) {
+	if oram.mode == TreeORAM {
+		oram.tree.rng = rng
+	}
+}
+
+func (oram *PathORAM) setLogger(l *Logger) {
+	oram.logger = l
+	if oram.mode == TreeORAM {
+		oram.stashLog.logger = l
+	}
+}
+
+func (oram *PathORAM) setRecorder(rec *Transcript) {
+	if oram.mode == TreeORAM {
+		oram.tree.rec = rec
+	} else {
+		oram.rec = rec
+	}
+}
+
+func (oram *PathORAM) Stats() ORAMStats {
+	out := oram.stats
+	if oram.mode == TreeORAM {
+		out.StashMax = oram.stashLog.stats.Max
+		oram.tree.addCryptoStats(&out)
 	}
+	return out
+}
+
+// Encrypts every bucket with AES-GCM under [key] (TreeORAM only; call before the first access)
+func (oram *PathORAM) EnableEncryption(key []byte) error {
+	assert(oram.mode == TreeORAM, "encryption requires TreeORAM")
+	return oram.tree.enableEncryption(key)
+}
+
+// Keeps a Merkle tree over the buckets and verifies every path read against the client's root hash.
+// A failed check fails the access with an *IntegrityError naming the bucket (TreeORAM only).
+func (oram *PathORAM) EnableIntegrity() {
+	assert(oram.mode == TreeORAM, "integrity checks require TreeORAM")
+	oram.tree.enableIntegrity()
+}
+
+// Fingerprint of the server's storage, e.g. to compare seeded runs (TreeORAM only)
+func (oram *PathORAM) ServerDigest() []byte {
+	assert(oram.mode == TreeORAM, "server digest requires TreeORAM")
+	return oram.tree.digest()
+}
+
+// Simulates a malicious server modifying bucket [n] (heap index, root = 1) (TreeORAM only)
+func (oram *PathORAM) CorruptBucket(n int) {
+	assert(oram.mode == TreeORAM, "corrupting a bucket requires TreeORAM")
+	oram.tree.corrupt(n)
 }
 
-// Access leaf (DUMMY)
-func (oram *PathORAM) readRmAccess(a addr, callerMsg string) Block {
+func (oram *PathORAM) logf(format string, args ...interface{}) {
+	oram.logger.logf(TagORAM, LevelDebug, format, args...)
+}
+
+// Access leaf: returns the block stored at [a] and removes it from the ORAM.
+// In TreeORAM the path stays open until the next [evict] / [evictWrite].
+func (oram *PathORAM) readRmAccess(a addr, callerMsg string) (Block, error) {
 	i := a.leaf
-	id := a.ctr
-	if i >= oram.nl {
-		log.Fatalf("ReadAndRm ACCESS leaf index out of bounds: i=%v, n=%v", i, oram.nl)
+	if i < 0 || i >= oram.nl {
+		return Block{}, leafErr(i, oram.nl)
 	}
 	if callerMsg != "" {
-		oram.log(fmt.Sprintf("ReadAndRm ACCESS: %v, called from: %v", a, callerMsg))
+		oram.logf("ReadAndRm ACCESS: %v, called from: %v", a, callerMsg)
 	} else {
-		oram.log(fmt.Sprintf("ReadAndRm ACCESS: %v", a))
+		oram.logf("ReadAndRm ACCESS: %v", a)
 	}
-	if v, ok := (oram.arr[i])[id]; ok {
-		// need to "Remove" from the PathORAM leaf after reading
-		delete(oram.arr[i], id)
-		return v
+	oram.stats.Accesses++
+	var v Block
+	var ok bool
+	if oram.mode == TreeORAM {
+		var err error
+		if v, ok, err = oram.treeReadRm(a); err != nil {
+			return Block{}, err
+		}
 	} else {
-		oram.log(fmt.Sprintf("Read yielded None when reading %v", a))
-		return Block{Data: NONE, IsNone: true}
+		var err error
+		if v, ok, err = oram.idealReadRm(a); err != nil {
+			return Block{}, err
+		}
+	}
+	if !ok {
+		oram.logf("Read yielded None when reading %v", a)
+		return Block{Data: NONE, IsNone: true}, nil
+	}
+	return v, nil
+}
+
+// IdealORAM: the server sees the path to [a.leaf] read, as in TreeORAM, and written back at the
+// [evict] that follows, never the leaf a written block goes to
+func (oram *PathORAM) idealReadRm(a addr) (Block, bool, error) {
+	if oram.openLeaf != NONE {
+		// previous access was never closed
+		if err := oram.evict(); err != nil {
+			return Block{}, false, err
+		}
+	}
+	oram.rec.record(0, OpRead, a.leaf, oram.idealPath(a.leaf))
+	oram.openLeaf = a.leaf
+	oram.stats.BlocksRead++
+	v, ok := (oram.arr[a.leaf])[a.ctr]
+	if ok {
+		// need to "Remove" from the PathORAM leaf after reading
+		delete(oram.arr[a.leaf], a.ctr)
+	}
+	return v, ok, nil
+}
+
+// Reads the whole path to [a.leaf] into the stash and takes [a] out of the stash.
+// The path is left open: it is written back by the [evict] that follows.
+func (oram *PathORAM) treeReadRm(a addr) (Block, bool, error) {
+	if oram.openLeaf != NONE {
+		// previous access was never closed
+		if err := oram.evict(); err != nil {
+			return Block{}, false, err
+		}
 	}
+	oram.stats.BlocksRead += (oram.tree.depth + 1) * oram.tree.z
+	if err := oram.tree.openPath(a.leaf); err != nil {
+		return Block{}, false, err
+	}
+	oram.openLeaf = a.leaf
+	oram.stash = append(oram.stash, oram.tree.readPath(a.leaf)...)
+	var v Block
+	found := false
+	for j, s := range oram.stash {
+		if s.a == a {
+			v = s.b
+			found = true
+			oram.stash = append(oram.stash[:j], oram.stash[j+1:]...)
+			break
+		}
+	}
+	return v, found, nil
+}
+
+// Bounds the stash size checked after every access (TreeORAM only).
+// [policy] decides whether exceeding [bound] fails the access with ErrStashOverflow or is just recorded.
+func (oram *PathORAM) SetStashBound(bound int, policy StashOverflowPolicy) {
+	assert(oram.mode == TreeORAM, "stash bound requires TreeORAM")
+	oram.stashLog.bound = bound
+	oram.stashLog.policy = policy
 }
 
-// NOTE: this is NOT the same functionality as [evict] in OSAM paper: we drop the stash for simulation;
-// and we just directly write [value] into the leaf corresponding to [a].
-// But this does not count as a separate "Access" of the ORAM: in real PathORAM implementation,
-// [value] would just be placed on the LCA with the preceding read-Acess path address and [a].
-func (oram *PathORAM) evictWrite(a addr, value interface{}) {
-	oram.log(fmt.Sprintf("Evict=Write: storing value %v at %v", value, a))
+// Also keeps the stash size after every access in StashStats().Series (TreeORAM only)
+func (oram *PathORAM) RecordStashSeries() {
+	assert(oram.mode == TreeORAM, "stash series requires TreeORAM")
+	oram.stashLog.series = true
+}
+
+// Stash occupancy recorded so far (TreeORAM only)
+func (oram *PathORAM) StashStats() StashStats {
+	assert(oram.mode == TreeORAM, "stash stats require TreeORAM")
+	return oram.stashLog.snapshot()
+}
+
+// Buckets an IdealORAM access reports, those of a TreeORAM of the same size
+func (oram *PathORAM) idealPath(leaf int) []int {
+	return heapPath(lg(nextPowTwo(oram.nl)), leaf)
+}
+
+// [evict] from the OSAM paper: greedily writes the stash back along the path opened by the
+// preceding [readRmAccess]. This is part of that access, not a separate one.
+// IdealORAM only records the write-back of that path.
+func (oram *PathORAM) evict() error {
+	if oram.openLeaf == NONE {
+		return nil
+	}
+	if oram.mode != TreeORAM {
+		oram.rec.record(0, OpWrite, oram.openLeaf, oram.idealPath(oram.openLeaf))
+		oram.stats.BlocksWritten++
+		oram.openLeaf = NONE
+		return nil
+	}
+	oram.stash = oram.tree.writePath(oram.openLeaf, oram.stash)
+	oram.tree.closePath(oram.openLeaf)
+	oram.stats.BlocksWritten += (oram.tree.depth + 1) * oram.tree.z
+	oram.openLeaf = NONE
+	return oram.stashLog.record(len(oram.stash))
+}
+
+// [evict] with a new block: [value] joins the stash and the open path is written back,
+// so [value] lands in the deepest bucket shared by the read path and [a.leaf] (their LCA),
+// or higher / in the stash if that bucket is full. No extra path is read.
+// IdealORAM: we drop the stash for simulation and directly write [value] into the leaf of [a];
+// the server still only sees the read path written back.
+func (oram *PathORAM) evictWrite(a addr, value interface{}) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	oram.logf("Evict=Write: storing value %v at %v", value, a)
+	if oram.mode == TreeORAM {
+		oram.stash = append(oram.stash, slot{a, Block{value, false}})
+		return oram.evict()
+	}
 	(oram.arr[a.leaf])[a.ctr] = Block{value, false}
+	return oram.evict()
+}
+
+func (oram *PathORAM) evictDummy(a addr) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	return oram.evict()
 }
@@ -1,26 +1,37 @@
 package osam_simulator
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 )
 
 // --------- OSAM ------------ //
 // see common.go for other type defs
+// Address bookkeeping: [allocs] only holds addresses that may still be read. Since every address
+// is read at most once, an address is dropped from all maps as soon as it is read, and dummy
+// addresses (read once by Write, never written) are not tracked at all. Addresses are numbered by
+// [counter], so an untracked address below it is dead and any other one was never alloc'd. Nothing
+// is kept per dead address: an address of another OSAM whose counter this one has already handed
+// out is taken for one of its own dead addresses (ErrDoubleRead rather than ErrNotAllocated).
 
 type OSAM struct {
 	counter int
-	oram    *PathORAM
-	print   bool
-	reads   map[addr]bool
-	writes  map[addr]bool
-	allocs  map[addr]bool
+	oram    ORAM
+	rng     *rand.Rand
+	logger  *Logger
+	writes  map[addr]bool // written, not read yet
+	allocs  map[addr]bool // alloc'd, not read yet
+	metrics *metrics
+	strict  bool        // panic on misuse instead of returning an error
+	mu      *sync.Mutex // nil unless EnableConcurrency was called
+
+	peakLive int // highest number of blocks stored in the ORAM at once
 }
 
-func (osam *OSAM) log(str string) {
-	if osam.print && !suppressPrint {
-		fmt.Println("[OSAM] " + str)
-	}
+func (osam *OSAM) logf(format string, args ...interface{}) {
+	osam.logger.logf(TagOSAM, LevelDebug, format, args...)
 }
 
 type QueueElem struct {
@@ -34,82 +45,271 @@ func (qe QueueElem) String() string {
 
 ////////////////////////////////////////
 
-func CreateOSAM(oram *PathORAM, print bool) *OSAM {
+// [rng] is the only randomness source of this OSAM and its ORAM: use SeededRand for
+// reproducible runs and SecureRand (or nil) for secure ones
+func CreateOSAM(oram ORAM, rng *rand.Rand) *OSAM {
+	if rng == nil {
+		rng = SecureRand()
+	}
 	o := &OSAM{}
 	o.counter = 0
 	o.oram = oram
-	o.print = print
-	o.reads = make(map[addr]bool)
+	o.rng = rng
+	oram.setRand(rng)
 	o.writes = make(map[addr]bool)
 	o.allocs = make(map[addr]bool)
+	o.metrics = createMetrics()
 	return o
 }
 
+// Makes this OSAM and every SmartPointer / BSP built on it safe for concurrent use. Each public
+// call (a pointer operation, Alloc, Read, Write, Metrics, ...) then runs atomically under one lock,
+// so concurrent calls are linearized in the order they acquire it. Call before sharing the OSAM.
+func (osam *OSAM) EnableConcurrency() {
+	osam.mu = &sync.Mutex{}
+}
+
+// Takes the OSAM lock (if concurrency is enabled) for one public call; internal code calls
+// the unexported variants, which never lock:
+//
+//	defer osam.lock()()
+func (osam *OSAM) lock() func() {
+	if osam.mu == nil {
+		return func() {}
+	}
+	osam.mu.Lock()
+	return osam.mu.Unlock
+}
+
+// Sends the log output of this OSAM, its ORAM and the pointers built on it to [l] (nil = discard)
+func (osam *OSAM) SetLogger(l *Logger) {
+	defer osam.lock()()
+	osam.logger = l
+	osam.oram.setLogger(l)
+}
+
+// Records every physical access of the underlying ORAM into [rec] (nil stops recording)
+func (osam *OSAM) SetRecorder(rec *Transcript) {
+	defer osam.lock()()
+	osam.oram.setRecorder(rec)
+}
+
 func (osam *OSAM) Alloc(msg string) addr {
-	leaf := rand.Intn(osam.oram.nl)
+	defer osam.lock()()
+	return osam.alloc(msg)
+}
+
+func (osam *OSAM) alloc(msg string) addr {
+	a := osam.freshAddr()
+	osam.allocs[a] = true
+	osam.logf("Alloc: %v for %v", a, msg)
+	return a
+}
+
+// Untracked address for a dummy access; it is dead as soon as it has been read
+func (osam *OSAM) dummyAddr() addr {
+	return osam.freshAddr()
+}
+
+func (osam *OSAM) freshAddr() addr {
+	leaf := osam.rng.Intn(osam.oram.numLeaves())
 	a := addr{osam.counter, leaf}
 	osam.counter++
-	osam.allocs[a] = true
-	osam.log(fmt.Sprintf("Alloc: %v for %v", a, msg))
+	osam.metrics.allocs++
 	return a
 }
 
-func (osam *OSAM) Read(a addr) Block {
-	assert(hasAddr(osam.allocs, a), fmt.Sprintf("Address %v has not been alloc'd", a))
-	assert(!hasAddr(osam.reads, a), fmt.Sprintf("Address %v has already been read", a))
-	osam.reads[a] = true
+// Whether [a] was handed out at some point but can never be read again
+func (osam *OSAM) isDead(a addr) bool {
+	return a.ctr >= 0 && a.ctr < osam.counter && !hasAddr(osam.allocs, a)
+}
+
+type AddrStats struct {
+	Allocated int // all addresses ever handed out, dummies included
+	Live      int // alloc'd and not read yet: still tracked by the OSAM
+	Stored    int // live and written, i.e. blocks currently in the ORAM
+	Dead      int // read (or dummy): reclaimed, nothing is kept for it
+}
+
+func (osam *OSAM) AddrStats() AddrStats {
+	defer osam.lock()()
+	return AddrStats{Allocated: osam.counter, Live: len(osam.allocs), Stored: len(osam.writes),
+		Dead: osam.counter - len(osam.allocs)}
+}
+
+// In strict mode every error of Read / Write panics instead of being returned
+func (osam *OSAM) SetStrict(strict bool) {
+	defer osam.lock()()
+	osam.strict = strict
+}
+
+func (osam *OSAM) fail(err error) error {
+	if osam.strict {
+		panic(err)
+	}
+	return err
+}
+
+// Errors: ErrDoubleRead, ErrNotAllocated, or an error of the ORAM (see errors.go).
+// If the ORAM fails only after taking the block out (e.g. ErrStashOverflow), [a] is read all
+// the same: its value is returned along with the error.
+func (osam *OSAM) Read(a addr) (Block, error) {
+	defer osam.lock()()
+	return osam.read(a)
+}
+
+func (osam *OSAM) read(a addr) (Block, error) {
+	if osam.isDead(a) {
+		return Block{}, osam.fail(addrErr(ErrDoubleRead, a))
+	}
+	if !hasAddr(osam.allocs, a) {
+		return Block{}, osam.fail(addrErr(ErrNotAllocated, a))
+	}
 	// 1. Read the value from address
-	v := osam.oram.readRmAccess(a, fmt.Sprintf("Read address %v", a))
-	// 2. Don't actually need to do Evict in our dummy implementation
-	return v
+	v, err := osam.oram.readRmAccess(a, fmt.Sprintf("Read address %v", a))
+	if err != nil {
+		return Block{}, osam.fail(err)
+	}
+	// the block is out of the ORAM: [a] is dead from here on
+	delete(osam.allocs, a)
+	delete(osam.writes, a)
+	// 2. Evict along the path just read
+	if err := osam.oram.evict(); err != nil {
+		return v, osam.fail(err)
+	}
+	return v, nil
 }
 
-func (osam *OSAM) Write(a addr, value interface{}, msg string) {
-	assert(hasAddr(osam.allocs, a), fmt.Sprintf("Address %v has not been alloc'd", a))
-	assert(!hasAddr(osam.writes, a), fmt.Sprintf("Address %v has already been written to", a))
-	osam.writes[a] = true
+// Errors: ErrDoubleWrite, ErrDoubleRead (writing a dead address), ErrNotAllocated,
+// or an error of the ORAM (see errors.go). After ErrStashOverflow the value is stored all the
+// same (a second Write to [a] is ErrDoubleWrite); after any other error it is not.
+func (osam *OSAM) Write(a addr, value interface{}, msg string) error {
+	defer osam.lock()()
+	return osam.write(a, value, msg)
+}
+
+func (osam *OSAM) write(a addr, value interface{}, msg string) error {
+	if osam.isDead(a) {
+		return osam.fail(addrErr(ErrDoubleRead, a))
+	}
+	if !hasAddr(osam.allocs, a) {
+		return osam.fail(addrErr(ErrNotAllocated, a))
+	}
+	if hasAddr(osam.writes, a) {
+		return osam.fail(addrErr(ErrDoubleWrite, a))
+	}
 	// 1. Simulate Read Access by reading a dummy address
-	osam.oram.readRmAccess(osam.Alloc(fmt.Sprintf("Write at addr %v (DUMMY)", a)), msg)
-	// 2. Do the Evict (in this dummy implementation, this directly places value at addr a)
-	osam.oram.evictWrite(a, value)
+	if _, err := osam.oram.readRmAccess(osam.dummyAddr(), msg); err != nil {
+		return osam.fail(err)
+	}
+	// 2. Do the Evict, placing value at the LCA of the dummy path and a's leaf
+	err := osam.oram.evictWrite(a, value)
+	if err != nil && !errors.Is(err, ErrStashOverflow) {
+		return osam.fail(err)
+	}
+	osam.writes[a] = true
+	if len(osam.writes) > osam.peakLive {
+		osam.peakLive = len(osam.writes)
+	}
+	if err != nil {
+		return osam.fail(err)
+	}
+	return nil
+}
+
+// Looks like a Write to the server but stores nothing; pads programs whose number of writes
+// would otherwise depend on secret data
+func (osam *OSAM) dummyWrite(msg string) error {
+	// like Write, one fresh address: a random path to read, then a random leaf to "store" at
+	a := osam.dummyAddr()
+	if _, err := osam.oram.readRmAccess(a, msg); err != nil {
+		return osam.fail(err)
+	}
+	if err := osam.oram.evictDummy(a); err != nil {
+		return osam.fail(err)
+	}
+	return nil
+}
+
+// Looks like a Read to the server (see [dummyWrite])
+func (osam *OSAM) dummyRead(msg string) error {
+	if _, err := osam.oram.readRmAccess(osam.dummyAddr(), msg); err != nil {
+		return osam.fail(err)
+	}
+	if err := osam.oram.evict(); err != nil {
+		return osam.fail(err)
+	}
+	return nil
+}
+
+// Read / Write for the pointer structures built on top of the OSAM, which have no error results
+// (see errors.go): any error panics
+
+func (osam *OSAM) mustRead(a addr) Block {
+	v, err := osam.read(a)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (osam *OSAM) mustWrite(a addr, value interface{}, msg string) {
+	if err := osam.write(a, value, msg); err != nil {
+		panic(err)
+	}
+}
+
+// Number of real blocks currently stored in the ORAM: this only grows without bound if the
+// program leaks (writes addresses it never reads back)
+func (osam *OSAM) LiveBlocks() int {
+	defer osam.lock()()
+	return len(osam.writes)
+}
+
+// Highest value LiveBlocks has reached
+func (osam *OSAM) PeakLiveBlocks() int {
+	defer osam.lock()()
+	return osam.peakLive
 }
 
 func (osam *OSAM) writeQE(a addr, value QueueElem) {
 	msg := fmt.Sprintf("Write(QE): %v @ address %v", value, a)
-	osam.Write(a, value, msg)
+	osam.mustWrite(a, value, msg)
 }
 
 func (osam *OSAM) writeN(a addr, value *Node) {
 	msg := fmt.Sprintf("Write(N): %v @ address %v", *value, a)
-	osam.Write(a, value, msg)
+	osam.mustWrite(a, value, msg)
 }
 
 func (osam *OSAM) writeBN(a addr, value *BNode) {
 	msg := fmt.Sprintf("Write(BN): %v @ address %v", *value, a)
-	osam.Write(a, value, msg)
+	osam.mustWrite(a, value, msg)
 }
 
 ///////////// QUEUE functionality ///////////////////
 
 func (osam *OSAM) initQueue() (addr, addr) {
-	head := osam.Alloc("initQueue")
+	head := osam.alloc("initQueue")
 	tail := head
 	return head, tail
 }
 
 func (osam *OSAM) enqueue(tail, a addr) addr {
-	newTail := osam.Alloc(fmt.Sprintf("enqueue addr %v at tail %v", a, tail))
+	osam.metrics.enqueues++
+	newTail := osam.alloc(fmt.Sprintf("enqueue addr %v at tail %v", a, tail))
 	osam.writeQE(tail, QueueElem{v: a, link: newTail})
 	return newTail
 
 }
 
 func (osam *OSAM) dequeue(head addr) (addr, addr) {
-	b := osam.Read(head)
+	osam.metrics.dequeues++
+	b := osam.mustRead(head)
 	if b.IsNone {
 		return NIL, NIL
 	} else {
-		bNode := b.Data.(QueueElem) // force data type to be a QueueElem
+		bNode := blockAs[QueueElem](b)
 		return bNode.v, bNode.link
 	}
 }
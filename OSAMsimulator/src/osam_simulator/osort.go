@@ -0,0 +1,114 @@
+package osam_simulator
+
+import "sort"
+
+// ------------- Oblivious sorting ------------- //
+// Bitonic sorting network (in the variant for arbitrary n): the sequence of compare-exchange
+// positions depends only on data.Len(), never on the data, so the memory accesses of a sort
+// reveal nothing but the number of items. Each compare-exchange does one Less and then a Swap
+// that is conditional only on its result, standing in for a branch-free swap.
+// O(n log^2 n) compare-exchanges.
+
+// Sorts [data] in place; returns the number of compare-exchanges done
+func BitonicSort(data sort.Interface) int {
+	s := bitonic{data: data}
+	s.sort(0, data.Len(), true)
+	return s.ops
+}
+
+// Number of compare-exchanges BitonicSort does on [n] items
+func BitonicOps(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	m := n / 2
+	return BitonicOps(m) + BitonicOps(n-m) + bitonicMergeOps(n)
+}
+
+func bitonicMergeOps(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	m := largestPowTwoBelow(n)
+	return n - m + bitonicMergeOps(m) + bitonicMergeOps(n-m)
+}
+
+type bitonic struct {
+	data sort.Interface
+	ops  int
+}
+
+// Sorts data[lo, lo+n) ascending if [up], descending otherwise
+func (s *bitonic) sort(lo, n int, up bool) {
+	if n <= 1 {
+		return
+	}
+	m := n / 2
+	s.sort(lo, m, !up)
+	s.sort(lo+m, n-m, up)
+	s.merge(lo, n, up)
+}
+
+// Merges the bitonic sequence data[lo, lo+n)
+func (s *bitonic) merge(lo, n int, up bool) {
+	if n <= 1 {
+		return
+	}
+	m := largestPowTwoBelow(n)
+	for i := lo; i < lo+n-m; i++ {
+		s.compareExchange(i, i+m, up)
+	}
+	s.merge(lo, m, up)
+	s.merge(lo+m, n-m, up)
+}
+
+func (s *bitonic) compareExchange(i, j int, up bool) {
+	s.ops++
+	var swap bool
+	if up {
+		swap = s.data.Less(j, i)
+	} else {
+		swap = s.data.Less(i, j)
+	}
+	if swap {
+		s.data.Swap(i, j)
+	}
+}
+
+// Returns the largest power of 2 < x (for x >= 2)
+func largestPowTwoBelow(x int) int {
+	return nextPowTwo(x) >> 1
+}
+
+// ------------- Bitonic sort on the perfect shuffle ------------- //
+// Stone's variant for n = 2^d items, for sequences that can only be streamed in order (e.g. linked
+// lists in the OSAM). Each of its d^2 steps takes items p and p+n/2 for every p < n/2, compare-
+// exchanges them, and writes them to positions 2p and 2p+1: the step interleaves the two halves
+// (a perfect shuffle), so after d steps every item is back at its position. Step s compares the
+// items that differ in bit d-1-(s mod d) of their original position; a step of phase k (s/d = k-1)
+// on a bit >= k passes the pairs through unchanged, so every step moves all n items.
+// (n/2) d^2 compare-exchanges, pass-throughs included.
+
+// Direction of pair [p] in step [s] of the shuffle sort of 2^[d] items: 1 = smaller item at 2p,
+// -1 = larger item at 2p, 0 = pass through
+func shuffleDir(d, s, p int) int {
+	k, t := s/d+1, s%d // phase k merges runs of 2^k; pairs differ in original bit d-1-t
+	if d-1-t >= k {
+		return 0
+	}
+	// original position of the item at p: p rotated right by t (mod d)
+	orig := p
+	if t > 0 {
+		orig = (p>>t | p<<(d-t)) & (1<<d - 1)
+	}
+	if k < d && orig>>k&1 == 1 {
+		return -1
+	}
+	return 1
+}
+
+// Number of compare-exchanges of the shuffle sort of [n] items (a power of 2)
+func ShuffleBitonicOps(n int) int {
+	d := lg(n)
+	return n / 2 * d * d
+}
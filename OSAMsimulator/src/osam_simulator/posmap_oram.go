@@ -0,0 +1,248 @@
+package osam_simulator
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// ------------- Path ORAM with a recursive position map ------------- //
+// Baseline for "plain ORAM + pointers": the leaf carried in an [addr] is ignored and every block's
+// position is looked up (and remapped) in a position map instead. The position map is packed
+// [PosMapPacking] entries per block into a smaller Path ORAM, recursively, until it has at most
+// [PosMapClientSize] entries, which the client then stores itself.
+// Block ids are the [addr] counters, so [capacity] must exceed the number of OSAM allocations.
+
+const (
+	PosMapPacking    = 8  // positions per position-map block
+	PosMapClientSize = 64 // largest position map kept by the client
+)
+
+type RecursiveORAM struct {
+	nl       int
+	capacity int
+	logger   *Logger
+	rng      *rand.Rand
+
+	data      *PathORAM
+	levels    []*PathORAM // levels[k] holds the positions of the blocks of level k-1 (level -1 = data)
+	clientMap []int       // positions of the blocks of the last level (NONE = never written)
+	peakStore int         // highest ClientStorage at the end of an access
+}
+
+func CreateRecursiveORAM(nleaves int, capacity int) *RecursiveORAM {
+	me := &RecursiveORAM{nl: nleaves, capacity: capacity, rng: SecureRand()}
+	me.data = CreateORAM(nleaves, TreeORAM)
+	n := capacity
+	for n > PosMapClientSize {
+		n = (n + PosMapPacking - 1) / PosMapPacking
+		lvl := CreateORAM(n, TreeORAM)
+		lvl.tree.id = len(me.levels) + 1
+		me.levels = append(me.levels, lvl)
+	}
+	me.clientMap = make([]int, n)
+	for i := range me.clientMap {
+		me.clientMap[i] = NONE
+	}
+	return me
+}
+
+func (oram *RecursiveORAM) logf(format string, args ...interface{}) {
+	oram.logger.logf(TagORAM, LevelDebug, format, args...)
+}
+
+func (oram *RecursiveORAM) numLeaves() int {
+	return oram.nl
+}
+
+func (oram *RecursiveORAM) setRand(rng *rand.Rand) {
+	oram.rng = rng
+	oram.data.setRand(rng)
+	for _, lvl := range oram.levels {
+		lvl.setRand(rng)
+	}
+}
+
+// Only the data ORAM logs: position-map accesses are part of the same logical access
+func (oram *RecursiveORAM) setLogger(l *Logger) {
+	oram.logger = l
+	oram.data.setLogger(l)
+}
+
+func (oram *RecursiveORAM) setRecorder(rec *Transcript) {
+	oram.data.setRecorder(rec)
+	for _, lvl := range oram.levels {
+		lvl.setRecorder(rec)
+	}
+}
+
+// Summed over the data ORAM and every position-map ORAM
+func (oram *RecursiveORAM) Stats() ORAMStats {
+	out := oram.data.Stats()
+	for _, lvl := range oram.levels {
+		s := lvl.Stats()
+		out.Accesses += s.Accesses
+		out.BlocksRead += s.BlocksRead
+		out.BlocksWritten += s.BlocksWritten
+		out.Encryptions += s.Encryptions
+		out.BytesRead += s.BytesRead
+		out.BytesWritten += s.BytesWritten
+		out.ServerBytes += s.ServerBytes
+		out.Hashes += s.Hashes
+		if s.StashMax > out.StashMax {
+			out.StashMax = s.StashMax
+		}
+	}
+	return out
+}
+
+// Encrypts the data ORAM and every position-map ORAM under [key]
+func (oram *RecursiveORAM) EnableEncryption(key []byte) error {
+	for _, o := range append([]*PathORAM{oram.data}, oram.levels...) {
+		if err := o.EnableEncryption(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merkle trees over the data ORAM and every position-map ORAM
+func (oram *RecursiveORAM) EnableIntegrity() {
+	oram.data.EnableIntegrity()
+	for _, o := range oram.levels {
+		o.EnableIntegrity()
+	}
+}
+
+// Number of blocks the client holds: the last position map level plus every stash
+func (oram *RecursiveORAM) ClientStorage() int {
+	out := len(oram.clientMap) + len(oram.data.stash)
+	for _, lvl := range oram.levels {
+		out += len(lvl.stash)
+	}
+	return out
+}
+
+// Highest ClientStorage at the end of an access, comparable to PathORAM's StashStats().Max
+func (oram *RecursiveORAM) PeakClientStorage() int {
+	return oram.peakStore
+}
+
+// Ends an access: records the client storage it leaves behind
+func (oram *RecursiveORAM) endAccess(err error) error {
+	if n := oram.ClientStorage(); n > oram.peakStore {
+		oram.peakStore = n
+	}
+	return err
+}
+
+// Number of position-map ORAMs between the data ORAM and the client map
+func (oram *RecursiveORAM) Levels() int {
+	return len(oram.levels)
+}
+
+// Looks up the position of block [id] of level [k] (k = -1 for the data ORAM)
+// and remaps it to a fresh random leaf. Returns (old position, new position).
+func (oram *RecursiveORAM) remap(k int, id int) (int, int, error) {
+	if k+1 == len(oram.levels) {
+		old := oram.clientMap[id]
+		oram.clientMap[id] = oram.rng.Intn(oram.leavesAt(k))
+		return old, oram.clientMap[id], nil
+	}
+	lvl := oram.levels[k+1]
+	b := id / PosMapPacking
+	oldB, newB, err := oram.remap(k+1, b)
+	if err != nil {
+		return NONE, NONE, err
+	}
+	if oldB == NONE { // block never written: read a random path instead
+		oldB = oram.rng.Intn(lvl.nl)
+	}
+	positions := make([]int, PosMapPacking)
+	blk, err := lvl.readRmAccess(addr{b, oldB}, "")
+	if err != nil {
+		return NONE, NONE, err
+	}
+	if !blk.IsNone {
+		copy(positions, blockAs[[]int](blk))
+	} else {
+		for j := range positions {
+			positions[j] = NONE
+		}
+	}
+	old := positions[id%PosMapPacking]
+	positions[id%PosMapPacking] = oram.rng.Intn(oram.leavesAt(k))
+	if err := lvl.evictWrite(addr{b, newB}, positions); err != nil {
+		return NONE, NONE, err
+	}
+	return old, positions[id%PosMapPacking], nil
+}
+
+// Number of leaves of level [k] (k = -1 for the data ORAM)
+func (oram *RecursiveORAM) leavesAt(k int) int {
+	if k < 0 {
+		return oram.data.nl
+	}
+	return oram.levels[k].nl
+}
+
+func (oram *RecursiveORAM) checkAddr(a addr) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	if a.ctr < 0 || a.ctr >= oram.capacity {
+		return fmt.Errorf("%w: id=%v, capacity=%v", ErrOutOfCapacity, a.ctr, oram.capacity)
+	}
+	return nil
+}
+
+// Position-map lookup for [a], then a Path ORAM access on the data tree
+func (oram *RecursiveORAM) readRmAccess(a addr, callerMsg string) (Block, error) {
+	if err := oram.checkAddr(a); err != nil {
+		return Block{}, err
+	}
+	oram.logf("ReadAndRm ACCESS: %v, called from: %v", a, callerMsg)
+	old, _, err := oram.remap(-1, a.ctr)
+	if err != nil {
+		return Block{}, err
+	}
+	if old == NONE {
+		old = oram.rng.Intn(oram.data.nl)
+	}
+	v, err := oram.data.readRmAccess(addr{a.ctr, old}, "")
+	if err != nil {
+		return Block{}, err
+	}
+	if v.IsNone {
+		oram.logf("Read yielded None when reading %v", a)
+	}
+	return v, nil
+}
+
+func (oram *RecursiveORAM) evict() error {
+	return oram.endAccess(oram.data.evict())
+}
+
+// Unlike OSAM, a write needs its own position-map update to choose where [a] goes next
+func (oram *RecursiveORAM) evictWrite(a addr, value interface{}) error {
+	if err := oram.checkAddr(a); err != nil {
+		return err
+	}
+	oram.logf("Evict=Write: storing value %v at %v", value, a)
+	_, pos, err := oram.remap(-1, a.ctr)
+	if err != nil {
+		return err
+	}
+	return oram.endAccess(oram.data.evictWrite(addr{a.ctr, pos}, value))
+}
+
+// Position-map update for [a] included, so it costs as much as [evictWrite]
+func (oram *RecursiveORAM) evictDummy(a addr) error {
+	if err := oram.checkAddr(a); err != nil {
+		return err
+	}
+	_, pos, err := oram.remap(-1, a.ctr)
+	if err != nil {
+		return err
+	}
+	return oram.endAccess(oram.data.evictDummy(addr{a.ctr, pos}))
+}
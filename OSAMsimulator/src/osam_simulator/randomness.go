@@ -0,0 +1,54 @@
+package osam_simulator
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+)
+
+// ------------- Randomness sources ------------- //
+// An OSAM and everything below it (ORAM backend, position maps, encryption nonces) draw their
+// randomness from the single source passed to CreateOSAM.
+
+// Deterministic source: the same seed replays the same leaves, nonces and traces.
+// The seed is scrambled first: math/rand streams of nearby seeds (run i uses seed+i) start out
+// correlated in their low bits, which is all Intn uses for a power-of-2 number of leaves.
+func SeededRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(int64(splitMix64(uint64(seed)))))
+}
+
+// SplitMix64 finalizer: a bijection that spreads every input bit over the output
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// Source backed by crypto/rand, for "secure" runs
+func SecureRand() *rand.Rand {
+	return rand.New(cryptoSource{})
+}
+
+type cryptoSource struct{}
+
+func (cryptoSource) Seed(int64) {}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// Fills [buf] from [rng]
+func randBytes(rng *rand.Rand, buf []byte) {
+	for i := range buf {
+		buf[i] = byte(rng.Uint32())
+	}
+}
@@ -0,0 +1,246 @@
+package osam_simulator
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// ------------- Ring ORAM ------------- //
+// Based on Ren et al., "Constants Count: Practical Improvements to Oblivious RAM" (USENIX Sec 2015).
+// Each bucket has Z real and S dummy slots under a secret permutation. An online access reads one
+// slot per bucket on the path (the requested block if it is there, else a fresh dummy), and the
+// server XORs these slots into a single block, so only one block crosses the wire.
+// Every A accesses a scheduled eviction reads and rewrites one path in reverse-lexicographic order,
+// and any bucket read S times since its last shuffle is reshuffled early.
+//
+// NOTE: slot offsets are not simulated: a bucket is its real blocks plus the number of times it
+// has been read since its last shuffle, which is all that decides when it runs out of dummies.
+
+const (
+	RingBucketSize = 4 // Z
+	RingDummies    = 6 // S
+	RingEvictRate  = 3 // A
+)
+
+type RingORAM struct {
+	nl     int
+	logger *Logger
+	stats  ORAMStats
+
+	tree     *bucketTree // real blocks of every bucket
+	reads    []int       // per bucket: slots read since the last shuffle
+	stash    []slot
+	stashLog *stashTracker
+	round    int // accesses since the last scheduled eviction
+	evictCtr int // next eviction path, taken in reverse-lexicographic order
+	openLeaf int // path read by the last [readRmAccess] and not yet closed (NONE if closed)
+	// per bucket: blocks taken by online reads, still in the server's copy until it is rewritten
+	taken map[int][]addr
+}
+
+func CreateRingORAM(nleaves int) *RingORAM {
+	me := &RingORAM{nl: nleaves, openLeaf: NONE, stashLog: createStashTracker(), taken: make(map[int][]addr)}
+	me.tree = createBucketTree(nleaves, RingBucketSize)
+	me.reads = make([]int, len(me.tree.buckets))
+	return me
+}
+
+func (oram *RingORAM) logf(format string, args ...interface{}) {
+	oram.logger.logf(TagORAM, LevelDebug, format, args...)
+}
+
+func (oram *RingORAM) numLeaves() int {
+	return oram.nl
+}
+
+func (oram *RingORAM) setRand(rng *rand.Rand) {
+	oram.tree.rng = rng
+}
+
+func (oram *RingORAM) setLogger(l *Logger) {
+	oram.logger = l
+	oram.stashLog.logger = l
+}
+
+func (oram *RingORAM) setRecorder(rec *Transcript) {
+	oram.tree.rec = rec
+}
+
+func (oram *RingORAM) Stats() ORAMStats {
+	out := oram.stats
+	out.StashMax = oram.stashLog.stats.Max
+	oram.tree.addCryptoStats(&out)
+	return out
+}
+
+// Same semantics as PathORAM.EnableEncryption
+// NOTE: buckets are sealed whole, where real Ring ORAM encrypts each slot separately.
+func (oram *RingORAM) EnableEncryption(key []byte) error {
+	return oram.tree.enableEncryption(key)
+}
+
+// Same semantics as PathORAM.EnableIntegrity
+func (oram *RingORAM) EnableIntegrity() {
+	oram.tree.enableIntegrity()
+}
+
+// Same semantics as PathORAM.SetStashBound
+func (oram *RingORAM) SetStashBound(bound int, policy StashOverflowPolicy) {
+	oram.stashLog.bound = bound
+	oram.stashLog.policy = policy
+}
+
+// Same semantics as PathORAM.RecordStashSeries
+func (oram *RingORAM) RecordStashSeries() {
+	oram.stashLog.series = true
+}
+
+func (oram *RingORAM) StashStats() StashStats {
+	return oram.stashLog.snapshot()
+}
+
+// Online read: one slot per bucket on the path to [a.leaf], XORed by the server into one block
+func (oram *RingORAM) readRmAccess(a addr, callerMsg string) (Block, error) {
+	i := a.leaf
+	if i < 0 || i >= oram.nl {
+		return Block{}, leafErr(i, oram.nl)
+	}
+	if oram.openLeaf != NONE {
+		// previous access was never closed
+		if err := oram.evict(); err != nil {
+			return Block{}, err
+		}
+	}
+	oram.logf("ReadAndRm ACCESS: %v, called from: %v", a, callerMsg)
+	oram.stats.Accesses++
+	oram.stats.BlocksRead++
+
+	buckets, err := oram.tree.peekPath(i)
+	if err != nil {
+		return Block{}, err
+	}
+	oram.openLeaf = i
+	v, found := takeSlot(&oram.stash, a)
+	for k, n := range oram.tree.path(i) {
+		// reads [a] if it is here, else a dummy: either way one more slot of this bucket is used up
+		if !found {
+			v, found = oram.takeOnline(n, buckets[k], a)
+		}
+		oram.reads[n]++
+		assert(oram.reads[n] <= RingDummies, fmt.Sprintf("Ring ORAM bucket %v ran out of dummies", n))
+	}
+	if !found {
+		oram.logf("Read yielded None when reading %v", a)
+		return Block{Data: NONE, IsNone: true}, nil
+	}
+	return v, nil
+}
+
+// Finds [a] among the blocks [slots] of bucket [n] that no online read has taken yet
+func (oram *RingORAM) takeOnline(n int, slots []slot, a addr) (Block, bool) {
+	for _, s := range slots {
+		if s.a == a && !containsAddr(oram.taken[n], a) {
+			oram.taken[n] = append(oram.taken[n], a)
+			return s.b, true
+		}
+	}
+	return Block{}, false
+}
+
+// Drops the blocks taken by online reads from the just opened bucket [n], before it is rewritten
+func (oram *RingORAM) dropTaken(n int) {
+	for _, a := range oram.taken[n] {
+		takeSlot(&oram.tree.buckets[n], a)
+	}
+	delete(oram.taken, n)
+}
+
+func containsAddr(as []addr, a addr) bool {
+	for _, x := range as {
+		if x == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Closes the access: early reshuffles on the path just read, then the scheduled eviction
+func (oram *RingORAM) evict() error {
+	if oram.openLeaf == NONE {
+		return nil
+	}
+	leaf := oram.openLeaf
+	oram.openLeaf = NONE
+	for _, n := range oram.tree.path(leaf) {
+		if oram.reads[n] >= RingDummies {
+			if err := oram.reshuffle(n); err != nil {
+				return err
+			}
+		}
+	}
+	oram.round++
+	if oram.round == RingEvictRate {
+		oram.round = 0
+		if err := oram.evictPath(oram.nextEvictLeaf()); err != nil {
+			return err
+		}
+	}
+	return oram.stashLog.record(len(oram.stash))
+}
+
+func (oram *RingORAM) evictWrite(a addr, value interface{}) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	oram.logf("Evict=Write: storing value %v at %v", value, a)
+	oram.stash = append(oram.stash, slot{a, Block{value, false}})
+	return oram.evict()
+}
+
+func (oram *RingORAM) evictDummy(a addr) error {
+	if a.leaf < 0 || a.leaf >= oram.nl {
+		return leafErr(a.leaf, oram.nl)
+	}
+	return oram.evict()
+}
+
+func (oram *RingORAM) nextEvictLeaf() int {
+	leaf := oram.tree.reverseLexLeaf(oram.evictCtr)
+	oram.evictCtr++
+	return leaf
+}
+
+// Reads the Z real slots of every bucket on the path to [leaf] into the stash,
+// then writes the path back greedily with freshly permuted buckets
+func (oram *RingORAM) evictPath(leaf int) error {
+	t := oram.tree
+	oram.stats.BlocksRead += (t.depth + 1) * RingBucketSize
+	oram.stats.BlocksWritten += (t.depth + 1) * (RingBucketSize + RingDummies)
+	if err := t.openPath(leaf); err != nil {
+		return err
+	}
+	for _, n := range t.path(leaf) {
+		oram.dropTaken(n)
+	}
+	oram.stash = append(oram.stash, t.readPath(leaf)...)
+	oram.stash = t.writePath(leaf, oram.stash)
+	t.closePath(leaf)
+	for _, n := range t.path(leaf) {
+		oram.reads[n] = 0
+	}
+	return nil
+}
+
+// Early reshuffle of bucket [n]: its remaining real blocks are read and rewritten with fresh dummies
+func (oram *RingORAM) reshuffle(n int) error {
+	oram.logf("Early reshuffle of bucket %v", n)
+	oram.stats.BlocksRead += RingBucketSize
+	oram.stats.BlocksWritten += RingBucketSize + RingDummies
+	if err := oram.tree.openBucket(n); err != nil {
+		return err
+	}
+	oram.dropTaken(n)
+	oram.tree.closeBucket(n)
+	oram.reads[n] = 0
+	return nil
+}
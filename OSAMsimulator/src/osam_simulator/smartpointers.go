@@ -3,23 +3,17 @@ package osam_simulator
 import "fmt"
 
 type SmartPointer struct {
-	osam      *OSAM
-	print     bool
-	nodeId    int
-	printPath bool
+	osam   *OSAM
+	nodeId int
 }
 
-func (sp *SmartPointer) log(str string, newline bool) {
-	if newline && !suppressPrint {
-		fmt.Println()
-	}
-	if sp.print && !suppressPrint {
-		fmt.Println("[SP] " + str)
-	}
+// API calls are logged at LevelInfo, the nodes they visit at LevelDebug
+func (sp *SmartPointer) logf(level LogLevel, format string, args ...interface{}) {
+	sp.osam.logger.logf(TagSP, level, format, args...)
 }
 
-func CreateSP(osam *OSAM, print bool, printPath bool) *SmartPointer {
-	return &SmartPointer{osam, print, 0, printPath}
+func CreateSP(osam *OSAM) *SmartPointer {
+	return &SmartPointer{osam, 0}
 }
 
 // defaults to all NIL values & intermediate node parameters otherwise
@@ -31,6 +25,7 @@ func (sp *SmartPointer) newNode() *Node {
 // ------------ SmartPointer helper functions ------------ //
 
 func (sp *SmartPointer) chase(head addr) *Node {
+	defer sp.osam.track("SP.chase")()
 	target := NIL
 	latest := NIL
 	tail := NIL
@@ -39,7 +34,7 @@ func (sp *SmartPointer) chase(head addr) *Node {
 		tail = head
 		target, head = sp.osam.dequeue(head)
 	}
-	nd := sp.osam.Read(latest).Data.(*Node)
+	nd := blockAs[*Node](sp.osam.mustRead(latest))
 	if nd.tailL == tail {
 		nd.tailL = NIL
 	} else {
@@ -49,7 +44,7 @@ func (sp *SmartPointer) chase(head addr) *Node {
 }
 
 func (sp *SmartPointer) saveNode(nd *Node) {
-	a := sp.osam.Alloc(fmt.Sprintf("saveNode %v", nd))
+	a := sp.osam.alloc(fmt.Sprintf("saveNode %v", nd))
 	if nd.tailL != NIL {
 		nd.tailL = sp.osam.enqueue(nd.tailL, a)
 	}
@@ -70,21 +65,18 @@ func (sp *SmartPointer) addTail(nd *Node) addr {
 }
 
 // Helper function that is the main body of Get and Put
-func (sp *SmartPointer) retrieve(p *Ptr, printPath bool) *Node {
+func (sp *SmartPointer) retrieve(p *Ptr) *Node {
+	defer sp.osam.track("SP.retrieve")()
 	nd := sp.chase(p.head)
 	p.head = sp.addTail(nd)
 	for !nd.isRoot {
-		if printPath {
-			fmt.Printf("Fetched SP-node: %v \n", nd.id)
-		}
+		sp.logf(LevelDebug, "Fetched SP-node: %v", nd.id)
 		parent := sp.chase(nd.headP)
 		nd.headP = sp.addTail(parent)
 		sp.saveNode(nd)
 		nd = parent
 	}
-	if printPath {
-		fmt.Printf("Fetched SP-node: %v \n", nd.id)
-	}
+	sp.logf(LevelDebug, "Fetched SP-node: %v", nd.id)
 	assert(nd.isRoot, "Node returned from [retrieve] is not root node")
 	return nd
 }
@@ -92,14 +84,16 @@ func (sp *SmartPointer) retrieve(p *Ptr, printPath bool) *Node {
 // ------------ SmartPointer: MAIN API ------------ //
 //  Get(p: Ptr) -> Block
 //  Put(p: Ptr, c: Block)
-//  IsNull(p: Ptr)
+//  IsNull(p: *Ptr)
 //  Copy(p1: Ptr) -> Ptr
 //  New(c: Block) -> Ptr
 //  Delete(p: Ptr)
 
 func (sp *SmartPointer) Get(p *Ptr) Block {
-	sp.log(fmt.Sprintf("GET: %v", p.head), true)
-	nd := sp.retrieve(p, sp.printPath)
+	defer sp.osam.lock()()
+	defer sp.osam.track("SP.Get")()
+	sp.logf(LevelInfo, "GET: %v", p.head)
+	nd := sp.retrieve(p)
 	// invariant after [retrieve]: nd.isRoot should be true
 	out := nd.content
 	sp.saveNode(nd)
@@ -107,27 +101,30 @@ func (sp *SmartPointer) Get(p *Ptr) Block {
 }
 
 func (sp *SmartPointer) Put(p *Ptr, c Block) {
-	sp.log(fmt.Sprintf("PUT: content '%v' @ %v", c.Data, p.head), true)
-	nd := sp.retrieve(p, sp.printPath)
+	defer sp.osam.lock()()
+	defer sp.osam.track("SP.Put")()
+	sp.logf(LevelInfo, "PUT: content '%v' @ %v", c.Data, p.head)
+	nd := sp.retrieve(p)
 	nd.content = c
 	sp.saveNode(nd)
 }
 
-func (sp *SmartPointer) IsNull(p Ptr) bool {
+func (sp *SmartPointer) IsNull(p *Ptr) bool {
+	defer sp.osam.lock()()
 	return p.head == NIL
 }
 
 func (sp *SmartPointer) Copy(p1 *Ptr) Ptr {
-	sp.log(fmt.Sprintf("COPY: starting to copy pointer %v", p1.head), true)
+	defer sp.osam.lock()()
+	defer sp.osam.track("SP.Copy")()
+	sp.logf(LevelInfo, "COPY: starting to copy pointer %v", p1.head)
 	nd := sp.chase(p1.head)
 	if nd.tailL != NIL || nd.tailR != NIL {
 		ndNew := sp.newNode()
-		if sp.printPath {
-			if nd.tailL == NIL {
-				fmt.Printf("Created node %v as L child of node %v \n", ndNew.id, nd.id)
-			} else {
-				fmt.Printf("Created node %v as R child of node %v \n", ndNew.id, nd.id)
-			}
+		if nd.tailL == NIL {
+			sp.logf(LevelDebug, "Created node %v as L child of node %v", ndNew.id, nd.id)
+		} else {
+			sp.logf(LevelDebug, "Created node %v as R child of node %v", ndNew.id, nd.id)
 		}
 		ndNew.headP = sp.addTail(nd)
 		// NOTE: paper says to do sp.saveNode(ndNew), but I think this is a typo and should be saveNode(nd)
@@ -141,7 +138,9 @@ func (sp *SmartPointer) Copy(p1 *Ptr) Ptr {
 }
 
 func (sp *SmartPointer) New(c Block) Ptr {
-	sp.log(fmt.Sprintf("NEW: starting to create pointer to content %v", c.Data), true)
+	defer sp.osam.lock()()
+	defer sp.osam.track("SP.New")()
+	sp.logf(LevelInfo, "NEW: starting to create pointer to content %v", c.Data)
 	nd := sp.newNode()
 	nd.isRoot = true
 	nd.content = c
@@ -151,13 +150,16 @@ func (sp *SmartPointer) New(c Block) Ptr {
 }
 
 func (sp *SmartPointer) Delete(p *Ptr) {
-	sp.log(fmt.Sprintf("DELETE: %v", p.head), true)
+	defer sp.osam.lock()()
+	defer sp.osam.track("SP.Delete")()
+	sp.logf(LevelInfo, "DELETE: %v", p.head)
 	if p.head != NIL {
 		nd := sp.chase(p.head)
 		if nd.isRoot {
 			if nd.tailL == NIL && nd.tailR == NIL {
 				// note: [chase] will have recently nulled-out one
-				sp.log(fmt.Sprintf("All pointers to %v deleted; should delete its content", nd), false)
+				// not saving the root back frees it together with its content
+				sp.logf(LevelInfo, "All pointers to %v deleted; freed its content", nd.id)
 			} else {
 				sp.saveNode(nd)
 			}
@@ -176,5 +178,6 @@ func (sp *SmartPointer) Delete(p *Ptr) {
 			}
 			sp.saveNode(nd)
 		}
+		p.head = NIL
 	}
 }
@@ -0,0 +1,76 @@
+package osam_simulator
+
+import "fmt"
+
+// ------------- Stash occupancy tracking ------------- //
+// Used by the tree-based ORAMs to check empirically that the client stash stays small.
+
+// What to do when the stash grows past its configured bound
+type StashOverflowPolicy int
+
+const (
+	// Record the overflow event and keep running
+	StashOverflowLog StashOverflowPolicy = iota
+	// Fail the access with ErrStashOverflow once it is over (a written block stays in the stash)
+	StashOverflowFail
+)
+
+// A single access after which the stash held more than the bound
+type StashOverflow struct {
+	Access int // index of the access (0-based)
+	Size   int
+	Bound  int
+}
+
+type StashStats struct {
+	Max       int
+	Accesses  int
+	Histogram map[int]int // stash size -> number of accesses that ended with that size
+	Series    []int       // stash size after each access, in order (only with RecordStashSeries)
+	Overflows []StashOverflow
+}
+
+type stashTracker struct {
+	bound  int // NONE = unbounded
+	policy StashOverflowPolicy
+	series bool // keep Series: one int per access, so off by default
+	stats  StashStats
+	logger *Logger
+}
+
+func createStashTracker() *stashTracker {
+	return &stashTracker{bound: NONE, stats: StashStats{Histogram: make(map[int]int)}}
+}
+
+// Records the stash size at the end of an access and checks it against the bound:
+// ErrStashOverflow if it is exceeded under StashOverflowFail
+func (st *stashTracker) record(size int) error {
+	access := st.stats.Accesses
+	st.stats.Accesses++
+	if st.series {
+		st.stats.Series = append(st.stats.Series, size)
+	}
+	st.stats.Histogram[size]++
+	if size > st.stats.Max {
+		st.stats.Max = size
+	}
+	if st.bound != NONE && size > st.bound {
+		st.stats.Overflows = append(st.stats.Overflows, StashOverflow{Access: access, Size: size, Bound: st.bound})
+		if st.policy == StashOverflowFail {
+			return fmt.Errorf("%w after access %v: size=%v, bound=%v", ErrStashOverflow, access, size, st.bound)
+		}
+		st.logger.logf(TagORAM, LevelWarn, "Stash overflow after access %v: size=%v, bound=%v", access, size, st.bound)
+	}
+	return nil
+}
+
+// Returns a copy of the stats, safe to keep across later accesses
+func (st *stashTracker) snapshot() StashStats {
+	out := StashStats{Max: st.stats.Max, Accesses: st.stats.Accesses, Histogram: make(map[int]int, len(st.stats.Histogram))}
+	for k, v := range st.stats.Histogram {
+		out.Histogram[k] = v
+	}
+	out.Series = append([]int(nil), st.stats.Series...)
+	out.Overflows = append([]StashOverflow(nil), st.stats.Overflows...)
+	return out
+}
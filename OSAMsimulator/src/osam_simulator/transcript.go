@@ -0,0 +1,85 @@
+package osam_simulator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ------------- Server-visible access transcript ------------- //
+// Records exactly what an adversarial server observes: for every physical access, which path
+// (leaf) was touched, whether it was read or written, and which buckets that covered.
+// Never contains block contents or OSAM addresses.
+
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+)
+
+type TranscriptEvent struct {
+	Seq     int    `json:"seq"`
+	Tree    int    `json:"tree"` // which server-side structure (position-map level for RecursiveORAM, else 0)
+	Op      string `json:"op"`
+	Leaf    int    `json:"leaf"`    // NONE when the access is not a path (single bucket, whole memory)
+	Buckets []int  `json:"buckets"` // heap indices (root = 1); empty when the whole memory is scanned
+}
+
+type Transcript struct {
+	Events []TranscriptEvent
+}
+
+func NewTranscript() *Transcript {
+	return &Transcript{}
+}
+
+func (t *Transcript) record(tree int, op string, leaf int, buckets []int) {
+	if t == nil {
+		return
+	}
+	t.Events = append(t.Events, TranscriptEvent{Seq: len(t.Events), Tree: tree, Op: op, Leaf: leaf,
+		Buckets: append([]int(nil), buckets...)})
+}
+
+// Leaves of all events of kind [op], in order
+func (t *Transcript) Leaves(op string) []int {
+	var out []int
+	for _, e := range t.Events {
+		if e.Op == op {
+			out = append(out, e.Leaf)
+		}
+	}
+	return out
+}
+
+// One JSON object per line
+func (t *Transcript) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range t.Events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Columns seq,tree,op,leaf,buckets with the buckets separated by ';'
+func (t *Transcript) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"seq", "tree", "op", "leaf", "buckets"}); err != nil {
+		return err
+	}
+	for _, e := range t.Events {
+		bs := make([]string, len(e.Buckets))
+		for i, b := range e.Buckets {
+			bs[i] = strconv.Itoa(b)
+		}
+		row := []string{strconv.Itoa(e.Seq), strconv.Itoa(e.Tree), e.Op, strconv.Itoa(e.Leaf), strings.Join(bs, ";")}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,48 @@
+package osam_simulator
+
+// ------------- Typed smart pointers ------------- //
+// Generic wrapper over any SmartPointerAPI so client code works with values of type T instead of
+// Blocks: New/Put take a T, Get returns a T (the zero T for a None block), and no casts are needed.
+// NOTE: with encryption enabled, non-basic payload types must still be registered with gob.
+
+type TypedPtr[T any] struct {
+	p Ptr
+}
+
+type TypedSP[T any] struct {
+	sp SmartPointerAPI
+}
+
+func CreateTypedSP[T any](sp SmartPointerAPI) *TypedSP[T] {
+	return &TypedSP[T]{sp: sp}
+}
+
+func (t *TypedSP[T]) New(v T) TypedPtr[T] {
+	return TypedPtr[T]{t.sp.New(Some(v))}
+}
+
+func (t *TypedSP[T]) Copy(p *TypedPtr[T]) TypedPtr[T] {
+	return TypedPtr[T]{t.sp.Copy(&p.p)}
+}
+
+func (t *TypedSP[T]) Get(p *TypedPtr[T]) T {
+	return blockAs[T](t.sp.Get(&p.p))
+}
+
+// Like Get, but also reports whether the pointee held a value
+func (t *TypedSP[T]) Lookup(p *TypedPtr[T]) (T, bool) {
+	b := t.sp.Get(&p.p)
+	return blockAs[T](b), !b.IsNone
+}
+
+func (t *TypedSP[T]) Put(p *TypedPtr[T], v T) {
+	t.sp.Put(&p.p, Some(v))
+}
+
+func (t *TypedSP[T]) Delete(p *TypedPtr[T]) {
+	t.sp.Delete(&p.p)
+}
+
+func (t *TypedSP[T]) IsNull(p *TypedPtr[T]) bool {
+	return t.sp.IsNull(&p.p)
+}